@@ -12,6 +12,7 @@ import (
 	"github.com/rs/zerolog"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var AnalyzeSupplierName = "analyze_supplier"
@@ -52,6 +53,18 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 		return nil, err
 	}
 
+	// Load all the task buffers of the supplier at once, the changes are
+	// saved at the end of the analysis
+	taskBuffers, err := records.LoadSupplierTaskBuffers(thisSupplierData.ID, aCtx.App.Config.Frameworks, aCtx.App.Mongodb, l)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("address", params.Supplier.Address).
+			Str("service", params.Supplier.Service).
+			Msg("Failed to load supplier task buffers.")
+		return nil, err
+	}
+
 	//--------------------------------------------------------------------------
 	// Update all tasks buffers
 	//--------------------------------------------------------------------------
@@ -74,7 +87,7 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 
 	} else {
 		// If the supplier entry exist we must cycle and check for pending results
-		LastSeenHeight, LastSeenTime, err = updateTasksSupplier(&thisSupplierData, params.Tests, aCtx.App.Config.Frameworks, aCtx.App.Mongodb, l)
+		LastSeenHeight, LastSeenTime, err = updateTasksSupplier(&thisSupplierData, taskBuffers, params.Tests, aCtx.App.Config.Frameworks, l)
 		if err != nil {
 			l.Error().
 				Err(err).
@@ -103,6 +116,28 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 	// Trigger incomplete tasks
 	//--------------------------------------------------------------------------
 
+	// Load the taxonomy summaries of the supplier once, the taxonomy
+	// dependencies of all the tasks are checked against them
+	taxonomySummaries, err := records.LoadTaxonomySummaries(thisSupplierData.ID, aCtx.App.Mongodb, l)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("address", params.Supplier.Address).
+			Str("service", params.Supplier.Service).
+			Msg("Failed to load supplier taxonomy summaries.")
+		return nil, err
+	}
+
+	// Get the requests in queue of all the tasks of the supplier at once
+	taskQueues, err := getSupplierTaskQueues(thisSupplierData.Address, thisSupplierData.Service, aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tasks that can receive samples, only used by the adaptive allocation
+	samplingCfg := aCtx.App.Config.Sampling
+	candidates := make([]*records.SamplingCandidate, 0)
+
 	// Loop over all tasks and frameworks
 	for _, test := range params.Tests {
 
@@ -115,7 +150,7 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 				Msg("Checking task requests.")
 
 			// Check taxonomy dependencies
-			depStatus, err := records.CheckTaxonomyDependency(&thisSupplierData, test.Framework, task, aCtx.App.Config.Frameworks, aCtx.App.Mongodb, l)
+			depStatus, err := records.CheckTaxonomyDependency(&thisSupplierData, test.Framework, task, aCtx.App.Config.Frameworks, taskBuffers, taxonomySummaries, l)
 			if err != nil {
 				l.Error().Err(err).
 					Msg("Could not check taxonomy dependencies.")
@@ -132,7 +167,7 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 			}
 
 			// Check task dependencies
-			depStatus, err = records.CheckTaskDependency(&thisSupplierData, test.Framework, task, aCtx.App.Config.Frameworks, aCtx.App.Mongodb, l)
+			depStatus, err = records.CheckTaskDependency(&thisSupplierData, test.Framework, task, aCtx.App.Config.Frameworks, taskBuffers, taxonomySummaries, l)
 			if err != nil {
 				l.Error().Err(err).
 					Msg("Could not check task dependencies.")
@@ -154,7 +189,7 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 				l.Error().Err(err).Msg("cannot retrieve task type")
 				return nil, fmt.Errorf("cannot retrieve task type")
 			}
-			thisTaskRecord, found := records.GetTaskData(thisSupplierData.ID, taskType, test.Framework, task, true, aCtx.App.Mongodb, l)
+			thisTaskRecord, found := taskBuffers.GetTaskData(taskType, test.Framework, task, true, l)
 			if found != true {
 				l.Error().
 					Str("address", thisSupplierData.Address).
@@ -186,6 +221,15 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 			// If the number of samples is less than the minimum or there is a minimum value to trigger, proceed to request more
 			numberOfSamples := thisTaskRecord.GetNumOkSamples()
 			l.Debug().Str("address", thisSupplierData.Address).Str("service", thisSupplierData.Service).Str("framework", test.Framework).Str("task", task).Uint32("numberOfSamples", numberOfSamples).Msg("Ok sample count.")
+
+			// With adaptive sampling all tasks are candidates, the samples are
+			// allocated once all of them are known
+			if samplingCfg != nil {
+				queue := getTaskQueue(taskQueues, test.Framework, task)
+				candidates = append(candidates, records.NewSamplingCandidate(test.Framework, task, thisTaskRecord, queue.InQueue, minTrigger, queue.Blacklist, aCtx.App.Config.Frameworks))
+				continue
+			}
+
 			if numberOfSamples < thisTaskRecord.GetMinSamplesPerTask() || minTrigger > 0 {
 
 				// Calculate the total number of request needed
@@ -197,10 +241,9 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 				}
 
 				// Get number of tasks in queue
-				inQueue, _, blackList, _, err := checkTaskDatabase(thisSupplierData.Address, thisSupplierData.Service, test.Framework, task, aCtx.App.Mongodb, l)
-				if err != nil {
-					return nil, err
-				}
+				queue := getTaskQueue(taskQueues, test.Framework, task)
+				inQueue := queue.InQueue
+				blackList := queue.Blacklist
 
 				// Only trigger if the tasks in queue are less than the maximum concurrent tasks that we allow
 				if maxConcurrentTasks > inQueue {
@@ -216,12 +259,14 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 
 						// Add trigger
 						thisTrigger := types.TaskTrigger{Address: thisSupplierData.Address,
-							Service:    thisSupplierData.Service,
-							Framework:  test.Framework,
-							Task:       task,
-							Blacklist:  blackList,
-							Qty:        int(reqNeeded),
-							RandomSeed: params.RandomSeed, // Copy through to all triggers
+							Service:     thisSupplierData.Service,
+							Framework:   test.Framework,
+							Task:        task,
+							Blacklist:   blackList,
+							Qty:         int(reqNeeded),
+							RandomSeed:  records.GetTriggerRandomSeed(aCtx.App.Config.Frameworks[test.Framework].SeedStrategy, params.RandomSeed, params.Supplier, test.Framework, task, params.Block),
+							NewSupplier: result.IsNew,
+							LastSeen:    thisTaskRecord.GetLastSeen(),
 						}
 						result.Triggers = append(result.Triggers, thisTrigger)
 					}
@@ -235,6 +280,49 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 		}
 	}
 
+	// Write all the buffer changes (cycled and created buffers)
+	err = taskBuffers.Save(aCtx.App.Mongodb, l)
+	if err != nil {
+		l.Error().Err(err).Str("address", params.Supplier.Address).Str("service", params.Supplier.Service).Msg("Failed to save supplier task buffers.")
+		return nil, err
+	}
+
+	if samplingCfg != nil {
+		records.AllocateSamples(candidates, samplingCfg)
+		for _, candidate := range candidates {
+			logEvent := l.Debug()
+			if candidate.Allocated > 0 {
+				logEvent = l.Info()
+			}
+			logEvent.
+				Str("address", thisSupplierData.Address).
+				Str("service", thisSupplierData.Service).
+				Str("framework", candidate.Framework).
+				Str("task", candidate.Task).
+				Uint32("num_ok_samples", candidate.Record.GetNumOkSamples()).
+				Uint32("in_queue", candidate.InQueue).
+				Float64("std_error", candidate.StdError).
+				Float64("deficit", candidate.Deficit).
+				Float64("nearness", candidate.Nearness).
+				Float64("priority", candidate.Priority).
+				Uint32("allocated", candidate.Allocated).
+				Msg("Sampling allocation.")
+			if candidate.Allocated == 0 {
+				continue
+			}
+			result.Triggers = append(result.Triggers, types.TaskTrigger{Address: thisSupplierData.Address,
+				Service:     thisSupplierData.Service,
+				Framework:   candidate.Framework,
+				Task:        candidate.Task,
+				Blacklist:   candidate.Blacklist,
+				Qty:         int(candidate.Allocated),
+				RandomSeed:  records.GetTriggerRandomSeed(aCtx.App.Config.Frameworks[candidate.Framework].SeedStrategy, params.RandomSeed, params.Supplier, candidate.Framework, candidate.Task, params.Block),
+				NewSupplier: result.IsNew,
+				LastSeen:    candidate.Record.GetLastSeen(),
+			})
+		}
+	}
+
 	result.Success = true
 
 	return &result, nil
@@ -242,9 +330,9 @@ func (aCtx *Ctx) AnalyzeSupplier(ctx context.Context, params types.AnalyzeSuppli
 
 // Checks for suppliers's tasks records and drops old ones.
 func updateTasksSupplier(supplierData *records.SupplierRecord,
+	taskBuffers *records.SupplierTaskBuffers,
 	tests []types.TestsData,
 	frameworkConfigMap map[string]types.FrameworkConfig,
-	mongoDB mongodb.MongoDb,
 	l *zerolog.Logger) (LastSeenHeight int64, LastSeenTime time.Time, err error) {
 
 	//--------------------------------------------------------------------------
@@ -268,7 +356,7 @@ func updateTasksSupplier(supplierData *records.SupplierRecord,
 			if err != nil {
 				return LastSeenHeight, LastSeenTime, err
 			}
-			thisTaskRecord, found := records.GetTaskData(supplierData.ID, taskType, test.Framework, task, false, mongoDB, l)
+			thisTaskRecord, found := taskBuffers.GetTaskData(taskType, test.Framework, task, false, l)
 
 			if !found {
 				l.Debug().
@@ -296,19 +384,16 @@ func updateTasksSupplier(supplierData *records.SupplierRecord,
 			}
 
 			//------------------------------------------------------------------
-			// Update task in DB
+			// Mark the task to be updated in DB
 			//------------------------------------------------------------------
-			if cycled || found {
+			if cycled {
 				l.Debug().
 					Str("address", supplierData.Address).
 					Str("service", supplierData.Service).
 					Str("framework", test.Framework).
 					Str("task", task).
 					Msg("Updating task entry.")
-				_, err = thisTaskRecord.UpdateTask(supplierData.ID, test.Framework, task, mongoDB, l)
-				if err != nil {
-					return LastSeenHeight, LastSeenTime, err
-				}
+				taskBuffers.SetChanged(taskType, test.Framework, task)
 			}
 
 			//------------------------------------------------------------------
@@ -334,102 +419,135 @@ func updateTasksSupplier(supplierData *records.SupplierRecord,
 	return LastSeenHeight, LastSeenTime, err
 }
 
-// Looks for a framework-task-suppliers in the TaskDB and retrieves all the IDs and tasks status
-func checkTaskDatabase(address string,
+// Requests status of a framework-task pair of a supplier in the TaskDB
+type taskQueueStatus struct {
+	// Samples requested and not done yet
+	InQueue uint32
+	// Number of done task requests
+	Done uint32
+	// Docs of the pending requests, they must not be requested again
+	Blacklist []int
+	TaskIDs   []primitive.ObjectID
+}
+
+// Key of the taskQueueStatus map, one entry per framework-task pair
+type taskQueueKey struct {
+	framework string
+	task      string
+}
+
+// A task request as returned by the getSupplierTaskQueues aggregation, the
+// instances only have their doc_id (one per instance)
+type taskQueueRecord struct {
+	Id        primitive.ObjectID     `bson:"_id"`
+	Framework string                 `bson:"framework"`
+	Task      string                 `bson:"tasks"`
+	Qty       int                    `bson:"qty"`
+	Done      bool                   `bson:"done"`
+	Instances []types.InstanceRecord `bson:"instances"`
+}
+
+// Retrieves the status of all the task requests of a supplier-service pair in
+// the TaskDB, with the doc ids of the instances of the pending ones. A single
+// aggregation is used for all the frameworks and tasks of the supplier.
+func getSupplierTaskQueues(address string,
 	service string,
-	framework string,
-	task string,
 	mongoDB mongodb.MongoDb,
-	l *zerolog.Logger) (tasksInQueue uint32,
-	tasksDone uint32,
-	blackList []int,
-	tasksIDs []primitive.ObjectID,
-	err error) {
-	// define blacklist as length zero
-	blackList = make([]int, 0)
+	l *zerolog.Logger) (queues map[taskQueueKey]*taskQueueStatus, err error) {
+
+	queues = make(map[taskQueueKey]*taskQueueStatus)
 
 	// Get tasks collection
 	tasksCollection := mongoDB.GetCollection(types.TaskCollection)
-	// Get tasks instances
-	instancesCollection := mongoDB.GetCollection(types.InstanceCollection)
 
-	// Set filtering for this supplier-service pair data
-	task_request_filter := bson.D{{Key: "requester_args.address", Value: address},
-		{Key: "requester_args.service", Value: service},
-		{Key: "framework", Value: framework},
-		{Key: "tasks", Value: task}}
+	// Get all the task requests of this supplier-service pair, joined with the
+	// doc ids of their instances (only for the pending ones)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "requester_args.address", Value: address},
+			{Key: "requester_args.service", Value: service},
+		}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: types.InstanceCollection},
+			{Key: "let", Value: bson.D{{Key: "task_id", Value: "$_id"}, {Key: "done", Value: "$done"}}},
+			{Key: "pipeline", Value: mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$and", Value: bson.A{
+					bson.D{{Key: "$eq", Value: bson.A{"$task_id", "$$task_id"}}},
+					bson.D{{Key: "$not", Value: bson.A{"$$done"}}},
+				}}}}}}},
+				{{Key: "$project", Value: bson.D{{Key: "_id", Value: 0}, {Key: "doc_id", Value: 1}}}},
+			}},
+			{Key: "as", Value: "instances"},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "framework", Value: 1},
+			{Key: "tasks", Value: 1},
+			{Key: "qty", Value: 1},
+			{Key: "done", Value: 1},
+			{Key: "instances", Value: 1},
+		}}},
+	}
 
 	// Set mongo context
 	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
-	// Now retrieve all supplier task requests entries
-	cursor, err := tasksCollection.Find(ctxM, task_request_filter)
+	cursor, err := tasksCollection.Aggregate(ctxM, pipeline)
 	if err != nil {
-		l.Error().Msg("Could not retrieve task request data from MongoDB.")
-		return tasksInQueue, tasksDone, blackList, tasksIDs, err
+		l.Error().Err(err).Str("address", address).Str("service", service).Msg("Could not retrieve task request data from MongoDB.")
+		return queues, err
 	}
 	defer cursor.Close(ctxM)
 	for cursor.Next(ctxM) {
-		var taskReq types.TaskRequestRecord
+		var taskReq taskQueueRecord
 		if err := cursor.Decode(&taskReq); err != nil {
-			l.Error().Msg("Could not decode task request data from MongoDB.")
-			return tasksInQueue, tasksDone, blackList, tasksIDs, err
+			l.Error().Err(err).Str("address", address).Str("service", service).Msg("Could not decode task request data from MongoDB.")
+			return queues, err
+		}
+		key := taskQueueKey{framework: taskReq.Framework, task: taskReq.Task}
+		queue, ok := queues[key]
+		if !ok {
+			queue = &taskQueueStatus{Blacklist: make([]int, 0)}
+			queues[key] = queue
 		}
 		// Save id
-		tasksIDs = append(tasksIDs, taskReq.Id)
-		// If not already done
+		queue.TaskIDs = append(queue.TaskIDs, taskReq.Id)
 		if !taskReq.Done {
-			l.Debug().
-				Str("address", address).
-				Str("service", service).
-				Str("framework", framework).
-				Str("task", task).
-				Msg("Found pending task.")
 			// Count pending
-			tasksInQueue += uint32(taskReq.Qty)
-
-			// Search for associated instances to retrieve ids
-			instances_filter := bson.D{{"task_id", taskReq.Id}}
-			cursor2, err := instancesCollection.Find(ctxM, instances_filter)
-			if err != nil {
-				l.Error().Msg("Could not retrieve instances data from MongoDB.")
-				return tasksInQueue, tasksDone, blackList, tasksIDs, err
-			}
-			defer cursor2.Close(ctxM)
-			// Get all ids
-			for cursor2.Next(ctxM) {
-				var thisInstance types.InstanceRecord
-				if err := cursor.Decode(&thisInstance); err != nil {
-					l.Error().Msg("Could not decode task request data from MongoDB.")
-					return tasksInQueue, tasksDone, blackList, tasksIDs, err
-				}
-				for _, docId := range thisInstance.DocIds {
-					blackList = append(blackList, docId)
-				}
+			queue.InQueue += uint32(taskReq.Qty)
+			for _, thisInstance := range taskReq.Instances {
+				queue.Blacklist = append(queue.Blacklist, thisInstance.DocID)
 			}
 		} else {
-			l.Debug().
-				Str("address", address).
-				Str("service", service).
-				Str("framework", framework).
-				Str("task", task).
-				Msg("Found done task.")
-			tasksDone += 1
+			queue.Done += 1
 		}
-
+	}
+	if err = cursor.Err(); err != nil {
+		l.Error().Err(err).Str("address", address).Str("service", service).Msg("Could not read task request data from MongoDB.")
+		return queues, err
 	}
 
-	l.Debug().
-		Str("address", address).
-		Str("service", service).
-		Str("framework", framework).
-		Str("task", task).
-		Int32("tasksDone", int32(tasksDone)).
-		Int32("tasksInQueue", int32(tasksInQueue)).
-		Int("tasksIDsLen", len(tasksIDs)).
-		Msg("Pending tasks analyzed.")
+	for key, queue := range queues {
+		l.Debug().
+			Str("address", address).
+			Str("service", service).
+			Str("framework", key.framework).
+			Str("task", key.task).
+			Int32("tasksDone", int32(queue.Done)).
+			Int32("tasksInQueue", int32(queue.InQueue)).
+			Int("blacklistLen", len(queue.Blacklist)).
+			Int("tasksIDsLen", len(queue.TaskIDs)).
+			Msg("Pending tasks analyzed.")
+	}
 
-	return tasksInQueue, tasksDone, blackList, tasksIDs, err
+	return queues, nil
+}
 
+// Returns the requests status of a framework-task pair, empty if the supplier
+// has no requests for it
+func getTaskQueue(queues map[taskQueueKey]*taskQueueStatus, framework string, task string) *taskQueueStatus {
+	if queue, ok := queues[taskQueueKey{framework: framework, task: task}]; ok {
+		return queue
+	}
+	return &taskQueueStatus{Blacklist: make([]int, 0)}
 }
@@ -0,0 +1,85 @@
+package activities
+
+import (
+	"manager/types"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// An instance as written by the lmeh generator (asdict of the lm-eval
+// Instance, plus the MongoDB fields), the doc_id is a scalar
+func lmehInstanceDocument(taskID primitive.ObjectID, docID int32) bson.D {
+	return bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "done", Value: false},
+		{Key: "task_id", Value: taskID},
+		{Key: "request_type", Value: "loglikelihood"},
+		{Key: "doc", Value: bson.D{{Key: "question", Value: "2 + 2 = ?"}, {Key: "choices", Value: bson.A{"3", "4"}}}},
+		{Key: "arguments", Value: bson.A{"2 + 2 = ?", " 4"}},
+		{Key: "idx", Value: int32(1)},
+		{Key: "metadata", Value: bson.A{"mmlu_abstract_algebra", docID, int32(1)}},
+		{Key: "resps", Value: bson.A{}},
+		{Key: "filtered_resps", Value: bson.D{}},
+		{Key: "task_name", Value: "mmlu_abstract_algebra"},
+		{Key: "doc_id", Value: docID},
+		{Key: "repeats", Value: int32(1)},
+	}
+}
+
+func TestInstanceRecordDecodesGeneratorDocument(t *testing.T) {
+	taskID := primitive.NewObjectID()
+	raw, err := bson.Marshal(lmehInstanceDocument(taskID, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var instance types.InstanceRecord
+	if err := bson.Unmarshal(raw, &instance); err != nil {
+		t.Fatalf("cannot decode instance: %s", err)
+	}
+	if instance.TaskID != taskID {
+		t.Errorf("task_id = %s, want %s", instance.TaskID.Hex(), taskID.Hex())
+	}
+	if instance.DocID != 12 {
+		t.Errorf("doc_id = %d, want 12", instance.DocID)
+	}
+}
+
+func TestTaskQueueRecordDecodesLookup(t *testing.T) {
+	// Output of the getSupplierTaskQueues aggregation for a pending task
+	taskID := primitive.NewObjectID()
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: taskID},
+		{Key: "framework", Value: "lmeh"},
+		{Key: "tasks", Value: "mmlu_abstract_algebra"},
+		{Key: "qty", Value: int32(3)},
+		{Key: "done", Value: false},
+		{Key: "instances", Value: bson.A{
+			bson.D{{Key: "doc_id", Value: int32(4)}},
+			bson.D{{Key: "doc_id", Value: int32(9)}},
+			bson.D{{Key: "doc_id", Value: int64(17)}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var taskReq taskQueueRecord
+	if err := bson.Unmarshal(raw, &taskReq); err != nil {
+		t.Fatalf("cannot decode task request: %s", err)
+	}
+	if taskReq.Id != taskID || taskReq.Framework != "lmeh" || taskReq.Task != "mmlu_abstract_algebra" || taskReq.Qty != 3 || taskReq.Done {
+		t.Errorf("unexpected task request %+v", taskReq)
+	}
+	want := []int{4, 9, 17}
+	if len(taskReq.Instances) != len(want) {
+		t.Fatalf("got %d instances, want %d", len(taskReq.Instances), len(want))
+	}
+	for i, instance := range taskReq.Instances {
+		if instance.DocID != want[i] {
+			t.Errorf("instance %d doc_id = %d, want %d", i, instance.DocID, want[i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package activities
+
+import (
+	"context"
+	"manager/records"
+	"manager/types"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var CleanOrphansName = "clean_orphans"
+
+// Scans a batch of documents of a task tree collection and removes the ones
+// whose task no longer exists. In dry-run mode the orphans are only reported.
+func (aCtx *Ctx) CleanOrphans(ctx context.Context, params types.CleanOrphansParams) (*types.CleanOrphansResults, error) {
+
+	var result types.CleanOrphansResults
+
+	// Get logger
+	l := aCtx.App.Logger
+	l.Debug().
+		Str("collection", params.Collection).
+		Str("after_id", params.AfterID.Hex()).
+		Bool("dry_run", params.DryRun).
+		Msg("Looking for orphan documents.")
+
+	collection, err := records.GetOrphanCollection(params.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, lastID, scanned, err := records.FindOrphans(collection, params.OlderThan, params.AfterID, int64(params.BatchSize), aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+	result.Scanned = uint(scanned)
+	result.Orphans = uint(len(orphans))
+	result.LastID = lastID
+	result.More = uint(scanned) >= params.BatchSize
+
+	// Distinct tasks of the orphans
+	result.OrphanTaskIDs = make([]primitive.ObjectID, 0)
+	seen := make(map[primitive.ObjectID]bool)
+	for _, orphan := range orphans {
+		if !seen[orphan.TaskID] {
+			seen[orphan.TaskID] = true
+			result.OrphanTaskIDs = append(result.OrphanTaskIDs, orphan.TaskID)
+		}
+	}
+
+	if !params.DryRun {
+		deleted, err := records.DeleteOrphans(collection, orphans, aCtx.App.Mongodb, l)
+		if err != nil {
+			return nil, err
+		}
+		result.Removed = uint(deleted)
+	}
+
+	if result.Orphans > 0 {
+		l.Info().
+			Str("collection", params.Collection).
+			Uint("scanned", result.Scanned).
+			Uint("orphans", result.Orphans).
+			Int("orphan_tasks", len(result.OrphanTaskIDs)).
+			Uint("removed", result.Removed).
+			Bool("dry_run", params.DryRun).
+			Msg("Orphan documents found.")
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,44 @@
+package activities
+
+import (
+	"context"
+	"manager/records"
+	"manager/types"
+)
+
+var ClusterBackendsName = "cluster_backends"
+
+func (aCtx *Ctx) ClusterBackends(ctx context.Context, params types.ClusterBackendsParams) (*types.ClusterBackendsResults, error) {
+
+	var result types.ClusterBackendsResults
+
+	// Get logger
+	l := aCtx.App.Logger
+	l.Debug().Time("since", params.Since).Msg("Clustering suppliers by backend fingerprint.")
+
+	groups, numSuppliers, err := records.ClusterSupplierBackends(params, aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+	result.Suppliers = uint(numSuppliers)
+
+	for _, group := range groups {
+		addresses := make([]string, len(group.Suppliers))
+		for i, member := range group.Suppliers {
+			addresses[i] = member.Address
+		}
+		l.Info().
+			Strs("addresses", addresses).
+			Int("links", len(group.Links)).
+			Msg("Suppliers seem to share a backend.")
+		result.Groups++
+		result.GroupedSuppliers += uint(len(group.Suppliers))
+	}
+
+	err = records.SaveSupplierBackendGroups(groups, aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
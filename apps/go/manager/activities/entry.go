@@ -51,4 +51,20 @@ func (aCtx *Ctx) Register(w worker.Worker) {
 		Name: AnalyzeResultName,
 	})
 
+	w.RegisterActivityWithOptions(aCtx.FindDuplicateResponses, activity.RegisterOptions{
+		Name: FindDuplicateResponsesName,
+	})
+
+	w.RegisterActivityWithOptions(aCtx.ClusterBackends, activity.RegisterOptions{
+		Name: ClusterBackendsName,
+	})
+
+	w.RegisterActivityWithOptions(aCtx.ReapStaleTasks, activity.RegisterOptions{
+		Name: ReapStaleTasksName,
+	})
+
+	w.RegisterActivityWithOptions(aCtx.CleanOrphans, activity.RegisterOptions{
+		Name: CleanOrphansName,
+	})
+
 }
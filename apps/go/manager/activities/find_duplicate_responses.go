@@ -0,0 +1,56 @@
+package activities
+
+import (
+	"context"
+	"manager/records"
+	"manager/types"
+	"time"
+)
+
+var FindDuplicateResponsesName = "find_duplicate_responses"
+
+func (aCtx *Ctx) FindDuplicateResponses(ctx context.Context, params types.FindDuplicateResponsesParams) (*types.FindDuplicateResponsesResults, error) {
+
+	var result types.FindDuplicateResponsesResults
+
+	// Get logger
+	l := aCtx.App.Logger
+	l.Debug().Time("since", params.Since).Msg("Looking for duplicate responses across suppliers.")
+
+	// The pairs found by this search are dated after this (MongoDB keeps
+	// milliseconds)
+	searchDate := time.Now().UTC().Truncate(time.Millisecond)
+	pairs, samples, err := records.FindDuplicateResponses(params, aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+	result.Samples = uint(samples)
+
+	for _, pair := range pairs {
+		if pair.Flagged {
+			l.Warn().
+				Str("supplier_a", pair.SupplierA).
+				Str("supplier_b", pair.SupplierB).
+				Int("shared_docs", pair.SharedDocs).
+				Int("exact_matches", pair.ExactMatches).
+				Int("near_matches", pair.NearMatches).
+				Float64("match_rate", pair.MatchRate).
+				Msg("Suppliers return identical responses, they might share a backend or replay responses.")
+			result.FlaggedPairs++
+		}
+		err = pair.Save(aCtx.App.Mongodb, l)
+		if err != nil {
+			return nil, err
+		}
+		result.ComparedPairs++
+	}
+
+	// Remove the pairs of previous searches that were not found again
+	deleted, err := records.DeleteStaleDuplicates(searchDate, aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+	l.Debug().Int64("deleted", deleted).Msg("Stale supplier duplicates deleted.")
+
+	return &result, nil
+}
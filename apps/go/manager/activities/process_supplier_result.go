@@ -41,7 +41,16 @@ func (aCtx *Ctx) AnalyzeResult(ctx context.Context, params types.AnalyzeResultPa
 	}
 	if taskData.Drop {
 		// The task has failed for some reason (result of mark_task_to_drop ),
-		// we cannot proceed and we must delete the task data
+		// we cannot proceed and we must delete the task data. Its relays are
+		// still recorded for the supplier availability.
+		var supplierData records.SupplierRecord
+		found, err := supplierData.FindAndLoadSupplier(types.SupplierData{
+			Address: taskData.RequesterArgs.Address,
+			Service: taskData.RequesterArgs.Service,
+		}, aCtx.App.Mongodb, l)
+		if err == nil && found {
+			recordTaskAvailability(&supplierData, params.TaskID, aCtx.App.Mongodb, l)
+		}
 		if !aCtx.App.Config.DevelopCfg.DoNotRemoveTasksFromDB {
 			RemoveTaskID(params.TaskID, aCtx.App.Mongodb, l)
 		}
@@ -87,7 +96,7 @@ func (aCtx *Ctx) AnalyzeResult(ctx context.Context, params types.AnalyzeResultPa
 	if err != nil {
 		return nil, err
 	}
-	thisTaskRecord, found := records.GetTaskData(supplierData.ID, taskType, taskData.Framework, taskData.Task, true, aCtx.App.Mongodb, l)
+	thisTaskRecord, found := records.GetTaskData(supplierData.ID, taskType, taskData.Framework, taskData.Task, aCtx.App.Config.Frameworks, true, aCtx.App.Mongodb, l)
 	if !found {
 		// Data should be found because we are creating it in the last
 		err = temporal.NewApplicationErrorWithCause("unable to get task buffer data", "GetTaskData", fmt.Errorf("Task %s not found", taskData.Task))
@@ -100,7 +109,10 @@ func (aCtx *Ctx) AnalyzeResult(ctx context.Context, params types.AnalyzeResultPa
 		return nil, err
 	}
 
-	thisTaskResults := thisTaskRecord.GetResultStruct()
+	thisTaskResults, err := records.NewResultRecord(taskType)
+	if err != nil {
+		return nil, err
+	}
 	found = false
 	found, err = thisTaskResults.FindAndLoadResults(params.TaskID,
 		resultsCollection,
@@ -183,19 +195,66 @@ func (aCtx *Ctx) AnalyzeResult(ctx context.Context, params types.AnalyzeResultPa
 		}
 
 	} else {
-		// TODO: handle status!=0
-		l.Debug().
+		// The evaluation failed, apply the policy configured for this status
+		status := thisTaskResults.GetStatus()
+		policy := records.GetEvaluationStatusPolicy(taskData.Framework, status, aCtx.App.Config.Frameworks)
+		maxRetries := records.GetMaxEvaluationRetries(taskData.Framework, aCtx.App.Config.Frameworks)
+		if policy == records.EvaluationPolicyRetry && taskData.EvaluationRetries >= maxRetries {
+			// Too many retries, the evaluator cannot process this task
+			policy = records.EvaluationPolicyEvaluator
+		}
+		l.Info().
 			Str("address", supplierData.Address).
 			Str("service", supplierData.Service).
 			Str("framework", taskData.Framework).
 			Str("task", taskData.Task).
 			Str("task_id", params.TaskID.String()).
+			Uint32("status", status).
+			Uint32("evaluation_retries", taskData.EvaluationRetries).
+			Str("policy", policy).
 			Msg("Status not zero.")
-	}
+		err = thisTaskRecord.AddEvaluationFailure(policy)
+		if err != nil {
+			l.Error().
+				Err(err).
+				Str("address", supplierData.Address).
+				Str("service", supplierData.Service).
+				Str("framework", taskData.Framework).
+				Str("task", taskData.Task).
+				Msg("Could not apply the evaluation policy.")
+			return nil, err
+		}
 
-	// Delete all MongoDB entries associated with this task ID
-	if !aCtx.App.Config.DevelopCfg.DoNotRemoveTasksFromDB {
-		RemoveTaskID(params.TaskID, aCtx.App.Mongodb, l)
+		if policy == records.EvaluationPolicySupplier {
+			// The supplier is responsible, count the failure in the buffer
+			err = records.InsertEvaluationFailureSample(thisTaskRecord, time.Now(), l)
+			if err != nil {
+				l.Error().
+					Err(err).
+					Str("address", supplierData.Address).
+					Str("service", supplierData.Service).
+					Str("framework", taskData.Framework).
+					Str("task", taskData.Task).
+					Msg("Could not insert the evaluation failure sample.")
+				return nil, err
+			}
+		}
+
+		if policy == records.EvaluationPolicyRetry {
+			// Save the retry count first, if the buffer was written by another
+			// process the activity fails and is retried with the new buffer
+			_, err = thisTaskRecord.UpdateTask(supplierData.ID, taskData.Framework, taskData.Task, aCtx.App.Mongodb, l)
+			if err != nil {
+				return nil, err
+			}
+			// Send the task back to the evaluator, keeping all its data
+			err = RetryTaskEvaluation(params.TaskID, aCtx.App.Mongodb, l)
+			if err != nil {
+				return nil, err
+			}
+			result.Success = true
+			return &result, nil
+		}
 	}
 
 	//------------------------------------------------------------------
@@ -207,11 +266,32 @@ func (aCtx *Ctx) AnalyzeResult(ctx context.Context, params types.AnalyzeResultPa
 	// Update task in DB
 	//------------------------------------------------------------------
 
+	// This is done before deleting the task, if the buffer was written by
+	// another process the activity fails and is retried with the new buffer
 	_, err = thisTaskRecord.UpdateTask(supplierData.ID, taskData.Framework, taskData.Task, aCtx.App.Mongodb, l)
 	if err != nil {
 		return nil, err
 	}
 
+	// Record the relays of the task before they are deleted
+	recordTaskAvailability(&supplierData, params.TaskID, aCtx.App.Mongodb, l)
+
+	// Delete all MongoDB entries associated with this task ID
+	if !aCtx.App.Config.DevelopCfg.DoNotRemoveTasksFromDB {
+		RemoveTaskID(params.TaskID, aCtx.App.Mongodb, l)
+	}
+
+	// Save the events produced by the task processing, if any. A failure here
+	// is not critical, the task data is already updated.
+	if emitter, ok := thisTaskRecord.(records.EventEmitter); ok {
+		_ = records.SaveSupplierEvents(emitter.PopEvents(), aCtx.App.Mongodb, l)
+	}
+
+	// Update the supplier reputation with the new task data
+	if aCtx.App.Config.Reputation != nil {
+		_ = supplierData.UpdateReputation(aCtx.App.Config.Reputation, aCtx.App.Mongodb, l)
+	}
+
 	result.Success = true
 
 	return &result, nil
@@ -245,6 +325,51 @@ func retrieveTaskData(taskID primitive.ObjectID,
 
 }
 
+// Adds the relays of a task to the supplier availability and refreshes the
+// supplier uptime. Errors are only logged (by the records functions), the
+// availability is not critical for the task analysis.
+func recordTaskAvailability(supplierData *records.SupplierRecord, taskID primitive.ObjectID, mongoDB mongodb.MongoDb, l *zerolog.Logger) {
+	err := records.RecordSupplierAvailability(supplierData.ID, taskID, mongoDB, l)
+	if err != nil {
+		return
+	}
+	_ = supplierData.UpdateUptime(mongoDB, l)
+}
+
+// Sends a task back to the evaluator: its result is deleted and the task is
+// marked as not evaluated, so the evaluator picks it up again.
+func RetryTaskEvaluation(taskID primitive.ObjectID, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+
+	// Delete the failed result
+	resultsCollection := mongoDB.GetCollection(types.ResultsCollection)
+	result_filter := bson.D{{Key: "result_data.task_id", Value: taskID}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	response, err := resultsCollection.DeleteMany(ctxM, result_filter)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not delete result data from MongoDB.")
+		return err
+	}
+	l.Debug().Int("deleted", int(response.DeletedCount)).Str("TaskID", taskID.String()).Msg("deleted result data from MongoDB")
+
+	// Mark the task to be evaluated again
+	tasksCollection := mongoDB.GetCollection(types.TaskCollection)
+	task_filter := bson.D{{Key: "_id", Value: taskID}}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "evaluated", Value: false}}},
+		{Key: "$inc", Value: bson.D{{Key: "evaluation_retries", Value: 1}}},
+	}
+	ctxM2, cancel2 := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel2()
+	_, err = tasksCollection.UpdateOne(ctxM2, task_filter, update)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not mark task for evaluation retry in MongoDB.")
+		return err
+	}
+
+	return nil
+}
+
 // Given a TaskID from MongoDB, deletes all associated entries from the "tasks", "instances", "prompts", "responses" and "results" collections.
 func RemoveTaskID(taskID primitive.ObjectID, mongoDB mongodb.MongoDb, l *zerolog.Logger) {
 
@@ -0,0 +1,183 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"manager/records"
+	"manager/types"
+	"time"
+)
+
+var ReapStaleTasksName = "reap_stale_tasks"
+
+// Closes the tasks that were not done before the given date. Their unfinished
+// docs are recorded as timeouts in the supplier task buffer and their prompts
+// without a response in the supplier availability, then the whole task tree is
+// removed.
+func (aCtx *Ctx) ReapStaleTasks(ctx context.Context, params types.ReapStaleTasksParams) (*types.ReapStaleTasksResults, error) {
+
+	var result types.ReapStaleTasksResults
+
+	// Get logger
+	l := aCtx.App.Logger
+	l.Debug().Time("older_than", params.OlderThan).Uint("batch_size", params.BatchSize).Msg("Reaping stale tasks.")
+
+	staleTasks, err := records.FindStaleTasks(params.OlderThan, int64(params.BatchSize), aCtx.App.Mongodb, l)
+	if err != nil {
+		return nil, err
+	}
+	for _, taskReq := range staleTasks {
+		timeoutSamples, recorded, err := aCtx.recordStaleTask(taskReq)
+		if err != nil {
+			// Keep the task, so the timeouts are recorded by the next run
+			l.Warn().
+				Err(err).
+				Str("task_id", taskReq.Id.String()).
+				Msg("Could not record stale task, skipping it.")
+			result.Skipped++
+			continue
+		}
+		if recorded {
+			result.TimeoutSamples += uint(timeoutSamples)
+		} else {
+			result.Unrecorded++
+		}
+
+		// Delete all MongoDB entries associated with this task ID
+		if !aCtx.App.Config.DevelopCfg.DoNotRemoveTasksFromDB {
+			RemoveTaskID(taskReq.Id, aCtx.App.Mongodb, l)
+		} else if err = records.CloseStaleTask(taskReq.Id, aCtx.App.Mongodb, l); err != nil {
+			return nil, err
+		}
+		result.Reaped++
+	}
+	// The skipped tasks are found again by the next batch, so only continue
+	// if this one made progress
+	result.More = uint(len(staleTasks)) >= params.BatchSize && result.Reaped > 0
+
+	l.Info().
+		Uint("reaped", result.Reaped).
+		Uint("timeout_samples", result.TimeoutSamples).
+		Uint("unrecorded", result.Unrecorded).
+		Uint("skipped", result.Skipped).
+		Bool("more", result.More).
+		Msg("Stale tasks reaped.")
+
+	return &result, nil
+}
+
+// Records the timeouts of a stale task against the supplier: one relay error
+// per prompt without a response in the availability and one timeout sample per
+// unfinished doc in the task buffer. Returns the number of timeout samples, or
+// false if the task cannot be recorded because the supplier or the task are
+// unknown (the task is removed anyway). DB errors are returned, the task must
+// be kept to record it later.
+func (aCtx *Ctx) recordStaleTask(taskReq types.TaskRequestRecord) (timeoutSamples int64, recorded bool, err error) {
+
+	l := aCtx.App.Logger
+
+	var supplierData records.SupplierRecord
+	found, err := supplierData.FindAndLoadSupplier(types.SupplierData{
+		Address: taskReq.RequesterArgs.Address,
+		Service: taskReq.RequesterArgs.Service,
+	}, aCtx.App.Mongodb, l)
+	if err != nil {
+		return 0, false, err
+	}
+	if !found {
+		l.Warn().
+			Str("task_id", taskReq.Id.String()).
+			Str("address", taskReq.RequesterArgs.Address).
+			Str("service", taskReq.RequesterArgs.Service).
+			Msg("Supplier of stale task not found, removing it without recording.")
+		return 0, false, nil
+	}
+	taskType, err := records.GetTaskType(taskReq.Framework, taskReq.Task, aCtx.App.Config.Frameworks, l)
+	if err != nil {
+		l.Warn().
+			Str("task_id", taskReq.Id.String()).
+			Str("framework", taskReq.Framework).
+			Str("task", taskReq.Task).
+			Msg("Stale task no longer configured, removing it without recording.")
+		return 0, false, nil
+	}
+	thisTaskRecord, found := records.GetTaskData(supplierData.ID, taskType, taskReq.Framework, taskReq.Task, aCtx.App.Config.Frameworks, true, aCtx.App.Mongodb, l)
+	if !found {
+		return 0, false, fmt.Errorf("cannot get the task buffer of %s:%s", taskReq.Framework, taskReq.Task)
+	}
+
+	timeouts, err := records.CountTaskTimeouts(taskReq.Id, aCtx.App.Mongodb, l)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// Each step is marked in the task when done, so if a later step fails the
+	// next run does not record it twice. The buffer goes first, since it can
+	// fail if the buffer is written concurrently.
+	if timeouts > 0 && !taskReq.StaleBufferRecorded {
+		timeoutSamples, err = records.CountUnfinishedInstances(taskReq, aCtx.App.Mongodb, l)
+		if err != nil {
+			return 0, false, err
+		}
+		if timeoutSamples > 0 {
+			err = records.InsertTimeoutSamples(thisTaskRecord, timeoutSamples, time.Now(), l)
+			if err != nil {
+				l.Error().
+					Err(err).
+					Str("address", supplierData.Address).
+					Str("service", supplierData.Service).
+					Str("framework", taskReq.Framework).
+					Str("task", taskReq.Task).
+					Msg("Could not insert timeout samples.")
+				return 0, false, err
+			}
+			thisTaskRecord.ProcessData(l)
+			_, err = thisTaskRecord.UpdateTask(supplierData.ID, taskReq.Framework, taskReq.Task, aCtx.App.Mongodb, l)
+			if err != nil {
+				return 0, false, err
+			}
+			if emitter, ok := thisTaskRecord.(records.EventEmitter); ok {
+				_ = records.SaveSupplierEvents(emitter.PopEvents(), aCtx.App.Mongodb, l)
+			}
+		}
+		err = records.MarkStaleTaskStep(taskReq.Id, records.StaleTaskBufferRecorded, aCtx.App.Mongodb, l)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	// Add the relays to the availability, this must be done before the task
+	// tree is deleted
+	if !taskReq.StaleAvailabilityRecorded {
+		err = records.RecordSupplierTimeouts(supplierData.ID, taskReq.Id, timeouts, aCtx.App.Mongodb, l)
+		if err != nil {
+			return 0, false, err
+		}
+		err = records.MarkStaleTaskStep(taskReq.Id, records.StaleTaskAvailabilityRecorded, aCtx.App.Mongodb, l)
+		if err != nil {
+			return 0, false, err
+		}
+		_ = supplierData.UpdateUptime(aCtx.App.Mongodb, l)
+	}
+
+	if timeouts == 0 {
+		// All prompts were answered, the task is stale for reasons that are
+		// not the supplier's fault
+		return 0, true, nil
+	}
+	if aCtx.App.Config.Reputation != nil {
+		_ = supplierData.UpdateReputation(aCtx.App.Config.Reputation, aCtx.App.Mongodb, l)
+	}
+
+	l.Debug().
+		Str("task_id", taskReq.Id.String()).
+		Str("address", supplierData.Address).
+		Str("service", supplierData.Service).
+		Str("framework", taskReq.Framework).
+		Str("task", taskReq.Task).
+		Int64("timeout_prompts", timeouts).
+		Int64("timeout_samples", timeoutSamples).
+		Msg("Stale task recorded as timeouts.")
+
+	return timeoutSamples, true, nil
+}
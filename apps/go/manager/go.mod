@@ -5,6 +5,7 @@ go 1.24.3
 toolchain go1.24.4
 
 require (
+	github.com/robfig/cron v1.2.0
 	github.com/rs/zerolog v1.34.0
 	go.mongodb.org/mongo-driver v1.15.0
 	go.temporal.io/api v1.29.1
@@ -192,7 +193,6 @@ require (
 	github.com/prometheus/procfs v0.15.1 // indirect
 	github.com/puzpuzpuz/xsync/v3 v3.1.0 // indirect
 	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
-	github.com/robfig/cron v1.2.0 // indirect
 	github.com/rogpeppe/go-internal v1.14.1 // indirect
 	github.com/rs/cors v1.11.1 // indirect
 	github.com/sagikazarmark/locafero v0.7.0 // indirect
@@ -0,0 +1,193 @@
+package records
+
+import (
+	"context"
+	"manager/types"
+	"packages/mongodb"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//------------------------------------------------------------------------------
+// Supplier availability
+//------------------------------------------------------------------------------
+
+// DB entry with the relay outcomes of a supplier during one hour. The entries
+// are only incremented, one per supplier and hour.
+type SupplierAvailabilityBucket struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	SupplierID primitive.ObjectID `bson:"supplier_id"`
+	Hour       time.Time          `bson:"hour"`
+	// All the relays made to the supplier
+	Total int64 `bson:"total"`
+	// Relays that the supplier answered, even if the answer was wrong
+	Up int64 `bson:"up"`
+	// Relays that the supplier did not answer (timeouts and relay errors)
+	Down int64 `bson:"down"`
+	// Number of relays by response code
+	Codes map[string]int64 `bson:"codes"`
+}
+
+// Returns whether a relay response code means that the supplier was up or down.
+// Other codes (i.e. out of session or errors of the requester itself) say
+// nothing about the supplier and are not used for the uptime.
+func relayCodeAvailability(code int) (up bool, down bool) {
+	switch code {
+	case RelayResponseCodes.Ok, RelayResponseCodes.Supplier:
+		return true, false
+	case RelayResponseCodes.Relay:
+		return false, true
+	}
+	return false, false
+}
+
+// Adds the outcome of all the relays of a task to the hourly availability
+// buckets of the supplier. The hour of a relay is taken from the creation time
+// of its response entry. This must be called once per task, before the task
+// responses are deleted.
+func RecordSupplierAvailability(supplierID primitive.ObjectID, taskID primitive.ObjectID, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+
+	// Get all the responses of the task
+	responsesCollection := mongoDB.GetCollection(types.ResponsesCollection)
+	response_filter := bson.D{{Key: "task_id", Value: taskID}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cursor, err := responsesCollection.Find(ctxM, response_filter)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not retrieve responses from MongoDB.")
+		return err
+	}
+	defer cursor.Close(ctxM)
+	var responses []types.RelayResponse
+	if err = cursor.All(ctxM, &responses); err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not decode responses from MongoDB.")
+		return err
+	}
+
+	// Bucket them by hour
+	buckets := make(map[time.Time]*SupplierAvailabilityBucket)
+	for _, response := range responses {
+		hour := response.Id.Timestamp().UTC().Truncate(time.Hour)
+		bucket, ok := buckets[hour]
+		if !ok {
+			bucket = &SupplierAvailabilityBucket{Hour: hour, Codes: make(map[string]int64)}
+			buckets[hour] = bucket
+		}
+		bucket.Total++
+		bucket.Codes[strconv.Itoa(response.Code)]++
+		up, down := relayCodeAvailability(response.Code)
+		if up {
+			bucket.Up++
+		} else if down {
+			bucket.Down++
+		}
+	}
+
+	// Add them to the stored buckets
+	availabilityCollection := mongoDB.GetCollection(types.SupplierAvailabilityCollection)
+	opts := options.Update().SetUpsert(true)
+	for hour, bucket := range buckets {
+		inc := bson.D{
+			{Key: "total", Value: bucket.Total},
+			{Key: "up", Value: bucket.Up},
+			{Key: "down", Value: bucket.Down},
+		}
+		for code, count := range bucket.Codes {
+			inc = append(inc, bson.E{Key: "codes." + code, Value: count})
+		}
+		bucket_filter := bson.D{{Key: "supplier_id", Value: supplierID}, {Key: "hour", Value: hour}}
+		update := bson.D{{Key: "$inc", Value: inc}}
+		ctxM2, cancel2 := context.WithTimeout(context.Background(), 20*time.Second)
+		_, err = availabilityCollection.UpdateOne(ctxM2, bucket_filter, update, opts)
+		cancel2()
+		if err != nil {
+			l.Error().Err(err).Str("TaskID", taskID.String()).Time("hour", hour).Msg("Could not update supplier availability in MongoDB.")
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the percentage of answered relays of a supplier since the given time
+// and the number of relays used to calculate it (without the ones that say
+// nothing about the supplier). The uptime is zero if there are no relays.
+func GetSupplierUptime(supplierID primitive.ObjectID, since time.Time, mongoDB mongodb.MongoDb, l *zerolog.Logger) (uptime float64, relays int64, err error) {
+
+	availabilityCollection := mongoDB.GetCollection(types.SupplierAvailabilityCollection)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "supplier_id", Value: supplierID},
+			{Key: "hour", Value: bson.D{{Key: "$gte", Value: since.UTC().Truncate(time.Hour)}}},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "up", Value: bson.D{{Key: "$sum", Value: "$up"}}},
+			{Key: "down", Value: bson.D{{Key: "$sum", Value: "$down"}}},
+		}}},
+	}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cursor, err := availabilityCollection.Aggregate(ctxM, pipeline)
+	if err != nil {
+		l.Error().Err(err).Str("supplier_id", supplierID.String()).Msg("Could not aggregate supplier availability from MongoDB.")
+		return 0, 0, err
+	}
+	defer cursor.Close(ctxM)
+
+	var totals []struct {
+		Up   int64 `bson:"up"`
+		Down int64 `bson:"down"`
+	}
+	if err = cursor.All(ctxM, &totals); err != nil {
+		l.Error().Err(err).Str("supplier_id", supplierID.String()).Msg("Could not decode supplier availability from MongoDB.")
+		return 0, 0, err
+	}
+	if len(totals) == 0 {
+		return 0, 0, nil
+	}
+	relays = totals[0].Up + totals[0].Down
+	if relays == 0 {
+		return 0, 0, nil
+	}
+	return 100 * float64(totals[0].Up) / float64(relays), relays, nil
+}
+
+// Re-calculates the rolling uptimes of the supplier and saves them. Only the
+// uptime fields are written, so concurrent updates of the supplier are kept.
+func (record *SupplierRecord) UpdateUptime(mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	now := time.Now().UTC()
+	uptime24h, _, err := GetSupplierUptime(record.ID, now.Add(-24*time.Hour), mongoDB, l)
+	if err != nil {
+		return err
+	}
+	uptime7d, _, err := GetSupplierUptime(record.ID, now.Add(-7*24*time.Hour), mongoDB, l)
+	if err != nil {
+		return err
+	}
+	record.Uptime24h = uptime24h
+	record.Uptime7d = uptime7d
+	record.UptimeUpdateTime = now
+
+	suppliersCollection := mongoDB.GetCollection(types.SuppliersCollection)
+	supplier_filter := bson.D{{Key: "_id", Value: record.ID}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "uptime_24h", Value: record.Uptime24h},
+		{Key: "uptime_7d", Value: record.Uptime7d},
+		{Key: "uptime_update_time", Value: record.UptimeUpdateTime},
+	}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err = suppliersCollection.UpdateOne(ctxM, supplier_filter, update)
+	if err != nil {
+		l.Error().Err(err).Str("address", record.Address).Str("service", record.Service).Msg("Could not update supplier uptime in MongoDB.")
+		return err
+	}
+	return nil
+}
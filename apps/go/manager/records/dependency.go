@@ -0,0 +1,300 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+
+	"github.com/rs/zerolog"
+)
+
+// ------------------------------------------------------------------------------
+// Dependency rules evaluation
+// ------------------------------------------------------------------------------
+
+// Metrics that can be used in taxonomy rules
+var TaxonomyMetrics = []string{
+	"score",
+	"score_lower_bound",
+	"score_dev",
+	"run_time",
+	"run_time_dev",
+	"sample_min",
+	"error_rate",
+	"success_rate",
+}
+
+// Evaluates a dependency rule (and its children) for a supplier. The framework
+// and task are the ones being checked, they are only used for logging and to
+// get the framework options. Task and taxonomy rules are resolved against the
+// loaded buffers and summaries of the supplier, not against the database.
+func evaluateDependencyRule(
+	rule types.DependencyRule,
+	supplierData *SupplierRecord,
+	framework string,
+	task string,
+	configMap map[string]types.FrameworkConfig,
+	taskBuffers *SupplierTaskBuffers,
+	taxonomySummaries map[string]types.TaxonomySummary,
+	l *zerolog.Logger) (bool, error) {
+
+	switch {
+	case rule.IsEmpty():
+		// No dependencies
+		l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("No dependency: Dependency OK")
+		return true, nil
+
+	case len(rule.All) > 0:
+		for _, child := range rule.All {
+			ok, err := evaluateDependencyRule(child, supplierData, framework, task, configMap, taskBuffers, taxonomySummaries, l)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case len(rule.Any) > 0:
+		for _, child := range rule.Any {
+			ok, err := evaluateDependencyRule(child, supplierData, framework, task, configMap, taskBuffers, taxonomySummaries, l)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case rule.IsTask():
+		return evaluateTaskRule(rule, supplierData, framework, task, configMap, taskBuffers, taxonomySummaries, l)
+
+	case rule.IsTaxonomy():
+		return evaluateTaxonomyRule(rule, supplierData, framework, task, configMap, taskBuffers, taxonomySummaries, l)
+	}
+
+	return false, fmt.Errorf("dependency rule cannot be processed")
+}
+
+func evaluateTaskRule(
+	rule types.DependencyRule,
+	supplierData *SupplierRecord,
+	framework string,
+	task string,
+	configMap map[string]types.FrameworkConfig,
+	taskBuffers *SupplierTaskBuffers,
+	taxonomySummaries map[string]types.TaxonomySummary,
+	l *zerolog.Logger) (bool, error) {
+
+	taskType, err := GetTaskType(rule.Framework, rule.Task, configMap, l)
+	if err != nil {
+		l.Error().Str("framework", framework).Str("task", task).Str("dep_framework", rule.Framework).Str("dep_task", rule.Task).Msg("Error getting task type")
+		return false, err
+	}
+	thisTaskRecord, found := taskBuffers.GetTaskData(taskType, rule.Framework, rule.Task, false, l)
+	if !found {
+		// The task is not even created, we must fail
+		return false, nil
+	}
+
+	depLog := func() *zerolog.Event {
+		return l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Str("dep_framework", rule.Framework).Str("dep_task", rule.Task)
+	}
+
+	if rule.Metric != "" {
+		value, ok := thisTaskRecord.GetMetric(rule.Metric)
+		if !ok {
+			l.Error().Str("framework", framework).Str("task", task).Str("metric", rule.Metric).Msg("dependency task does not provide the requested metric")
+			return false, nil
+		}
+		met, err := types.CompareRuleValue(value, rule.Op, rule.Value.Number)
+		if err != nil {
+			return false, err
+		}
+		depLog().Str("metric", rule.Metric).Float64("value", value).Bool("met", met).Msg("METRIC: Dependency checked")
+		return met, nil
+	}
+
+	switch rule.Status {
+	case types.DependencyStatusPresent:
+		// Task is present, so OK
+		depLog().Msg("Present: Dependency OK")
+		return true, nil
+
+	case types.DependencyStatusOK:
+		// Check for it having a correct value
+		met := thisTaskRecord.IsOK()
+		depLog().Bool("met", met).Msg("OK: Dependency checked")
+		return met, nil
+
+	case types.DependencyStatusEqual:
+		var matchValue interface{} = rule.Value.Text
+		if rule.Value.IsNumber {
+			matchValue = rule.Value.Number
+		}
+		met, err := thisTaskRecord.IsEqual(matchValue)
+		if err != nil {
+			l.Error().Err(err).Str("framework", framework).Str("task", task).Msg("Equality check failed")
+			return false, nil
+		}
+		depLog().Bool("met", met).Msg("EQUAL: Dependency checked")
+		return met, nil
+	}
+
+	l.Error().Str("framework", framework).Str("task", task).Str("status", rule.Status).Msg("dependency configuration cannot be processed (status type unknown)")
+	return false, fmt.Errorf("unknown dependency status %s", rule.Status)
+}
+
+func evaluateTaxonomyRule(
+	rule types.DependencyRule,
+	supplierData *SupplierRecord,
+	framework string,
+	task string,
+	configMap map[string]types.FrameworkConfig,
+	taskBuffers *SupplierTaskBuffers,
+	taxonomySummaries map[string]types.TaxonomySummary,
+	l *zerolog.Logger) (bool, error) {
+
+	// Get the taxonomy to evaluate
+	thisTaxonomySummary, found := taxonomySummaries[rule.Taxonomy]
+	if !found {
+		l.Debug().Str("supplier_id", supplierData.ID.String()).Str("taxonomy", rule.Taxonomy).Msg("Taxonomy summary not found")
+		// The taxonomy is not even summarized, we must fail
+		return false, nil
+	}
+
+	// Check the condition over the requested node
+	node := rule.GetNode()
+	taxonomyNode, found := thisTaxonomySummary.TaxonomyNodesScores[node]
+	if !found {
+		l.Error().Str("framework", framework).Str("task", task).Str("taxonomy", rule.Taxonomy).Str("node", node).Msg("malformed taxonomy summary, node not found!")
+		return false, nil
+	}
+
+	// The score is compared against the point estimate or the lower bound of
+	// its confidence interval, depending on the framework config
+	metric := rule.Metric
+	if metric == "score" && configMap[framework].TaxonomyGateOnLowerBound {
+		metric = "score_lower_bound"
+	}
+	value, ok := getTaxonomyNodeMetric(taxonomyNode, metric)
+	if !ok {
+		return false, fmt.Errorf("unknown taxonomy metric %s", metric)
+	}
+
+	met, err := types.CompareRuleValue(value, rule.Op, rule.Value.Number)
+	if err != nil {
+		return false, err
+	}
+	l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Str("taxonomy", rule.Taxonomy).Str("node", node).Str("metric", metric).Float64("value", value).Bool("met", met).Msg("TAXONOMY: Dependency checked")
+	return met, nil
+}
+
+// Returns the value of a named metric of a taxonomy node
+func getTaxonomyNodeMetric(node types.TaxonomyNode, metric string) (float64, bool) {
+	switch metric {
+	case "score":
+		return node.Score, true
+	case "score_lower_bound":
+		return taxonomyScoreLowerBound(node), true
+	case "score_dev":
+		return node.ScoreDev, true
+	case "run_time":
+		return node.RunTime, true
+	case "run_time_dev":
+		return node.RunTimeDev, true
+	case "sample_min":
+		return float64(node.SampleMin), true
+	case "error_rate":
+		return node.ErrorRate, true
+	case "success_rate":
+		return 1 - node.ErrorRate, true
+	}
+	return 0, false
+}
+
+// Returns the lower bound of the confidence interval of a taxonomy node score.
+// The node ScoreDev is the deviation of the mean (calculated by the summarizer
+// from the tasks standard errors), so a normal interval is used.
+func taxonomyScoreLowerBound(node types.TaxonomyNode) float64 {
+	return node.Score - scoreConfidenceZ*node.ScoreDev
+}
+
+// ------------------------------------------------------------------------------
+// Dependency rules validation
+// ------------------------------------------------------------------------------
+
+// Checks the dependency and schedule rules of all frameworks: their structure,
+// that the referenced framework-task pairs have a task type and that the
+// metrics exist for that task type.
+func ValidateDependencyRules(configMap map[string]types.FrameworkConfig) error {
+	for framework, frameworkCfg := range configMap {
+		err := frameworkCfg.Validate()
+		if err != nil {
+			return fmt.Errorf("framework %s: %s", framework, err.Error())
+		}
+		for task, rules := range frameworkCfg.TasksDependency {
+			if len(rules) == 0 {
+				return fmt.Errorf("framework %s: task_dependency %s is empty, use [\"none\"] for no dependencies", framework, task)
+			}
+			for idx, rule := range rules {
+				if err := validateRuleReferences(rule, configMap); err != nil {
+					return fmt.Errorf("framework %s: task_dependency %s, entry %d: %s", framework, task, idx, err.Error())
+				}
+			}
+		}
+		for task, rules := range frameworkCfg.TaxonomyDependency {
+			if len(rules) == 0 {
+				return fmt.Errorf("framework %s: taxonomy_dependency %s is empty, use [\"none:none:none:none\"] for no dependencies", framework, task)
+			}
+			for idx, rule := range rules {
+				if err := validateRuleReferences(rule, configMap); err != nil {
+					return fmt.Errorf("framework %s: taxonomy_dependency %s, entry %d: %s", framework, task, idx, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateRuleReferences(rule types.DependencyRule, configMap map[string]types.FrameworkConfig) error {
+	for _, children := range [][]types.DependencyRule{rule.All, rule.Any} {
+		for _, child := range children {
+			if err := validateRuleReferences(child, configMap); err != nil {
+				return err
+			}
+		}
+	}
+
+	if rule.IsTask() {
+		if _, ok := configMap[rule.Framework]; !ok {
+			return fmt.Errorf("unknown framework %s", rule.Framework)
+		}
+		taskType, err := GetTaskType(rule.Framework, rule.Task, configMap, &nopLogger)
+		if err != nil {
+			return fmt.Errorf("framework %s, task %s: %s", rule.Framework, rule.Task, err.Error())
+		}
+		if rule.Metric != "" && !TaskTypeHasMetric(taskType, rule.Metric) {
+			registration, _ := GetTaskTypeRegistration(taskType)
+			return fmt.Errorf("task type %s (framework %s, task %s) has no metric %q (available: %v)",
+				taskType, rule.Framework, rule.Task, rule.Metric, registration.Metrics)
+		}
+		if rule.Status == types.DependencyStatusEqual && !TaskTypeSupportsEqual(taskType) {
+			return fmt.Errorf("task type %s (framework %s, task %s) does not support status %q",
+				taskType, rule.Framework, rule.Task, rule.Status)
+		}
+	}
+
+	if rule.IsTaxonomy() {
+		found := false
+		for _, name := range TaxonomyMetrics {
+			if name == rule.Metric {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("taxonomy %s has no metric %q (available: %v)", rule.Taxonomy, rule.Metric, TaxonomyMetrics)
+		}
+	}
+
+	return nil
+}
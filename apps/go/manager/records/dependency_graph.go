@@ -0,0 +1,274 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+	"sort"
+	"strings"
+)
+
+// ------------------------------------------------------------------------------
+// Task Dependency Graph
+// ------------------------------------------------------------------------------
+
+// A framework-task pair in the dependency graph. The task can be "any", which
+// stands for all the tasks of the framework without a specific entry.
+type TaskNode struct {
+	Framework string
+	Task      string
+}
+
+func (node TaskNode) String() string {
+	return node.Framework + ":" + node.Task
+}
+
+// Graph of the task dependencies of all frameworks. There is one entry for
+// each `task_dependency` key of each framework, with edges to the
+// framework-task pairs referenced by its rules.
+type DependencyGraph struct {
+	Edges map[TaskNode][]TaskNode
+}
+
+// Builds the dependency graph from the frameworks configuration. It fails if a
+// referenced framework-task pair cannot be resolved to a dependency entry,
+// since the dependency check of that task would fail forever.
+func BuildDependencyGraph(configMap map[string]types.FrameworkConfig) (*DependencyGraph, error) {
+	graph := DependencyGraph{Edges: make(map[TaskNode][]TaskNode)}
+
+	for framework, frameworkCfg := range configMap {
+		for task, rules := range frameworkCfg.TasksDependency {
+			node := TaskNode{Framework: framework, Task: task}
+			seen := make(map[TaskNode]bool)
+			refs := make([]TaskNode, 0)
+			for _, rule := range rules {
+				collectTaskReferences(rule, seen, &refs)
+			}
+			sortTaskNodes(refs)
+			graph.Edges[node] = refs
+		}
+	}
+
+	// Check that all references resolve
+	for _, node := range graph.GetNodes() {
+		for _, ref := range graph.Edges[node] {
+			if _, ok := configMap[ref.Framework]; !ok {
+				return nil, fmt.Errorf("%s depends on %s, but framework %s is not configured", node, ref, ref.Framework)
+			}
+			if _, ok := graph.resolve(ref); !ok {
+				return nil, fmt.Errorf("%s depends on %s, but framework %s has no task_dependency entry for task %s (nor \"any\")",
+					node, ref, ref.Framework, ref.Task)
+			}
+		}
+	}
+
+	return &graph, nil
+}
+
+// Collects the framework-task pairs referenced by a rule and its children
+func collectTaskReferences(rule types.DependencyRule, seen map[TaskNode]bool, refs *[]TaskNode) {
+	for _, children := range [][]types.DependencyRule{rule.All, rule.Any} {
+		for _, child := range children {
+			collectTaskReferences(child, seen, refs)
+		}
+	}
+	if rule.IsTask() {
+		ref := TaskNode{Framework: rule.Framework, Task: rule.Task}
+		if !seen[ref] {
+			seen[ref] = true
+			*refs = append(*refs, ref)
+		}
+	}
+}
+
+// Returns the node holding the dependencies of the given framework-task pair,
+// the specific entry or the "any" entry of the framework.
+func (graph *DependencyGraph) resolve(node TaskNode) (TaskNode, bool) {
+	if _, ok := graph.Edges[node]; ok {
+		return node, true
+	}
+	anyNode := TaskNode{Framework: node.Framework, Task: "any"}
+	if _, ok := graph.Edges[anyNode]; ok {
+		return anyNode, true
+	}
+	return TaskNode{}, false
+}
+
+// Returns the nodes with a dependency entry, sorted
+func (graph *DependencyGraph) GetNodes() []TaskNode {
+	nodes := make([]TaskNode, 0, len(graph.Edges))
+	for node := range graph.Edges {
+		nodes = append(nodes, node)
+	}
+	sortTaskNodes(nodes)
+	return nodes
+}
+
+// Returns an error describing the first dependency cycle found, if any
+func (graph *DependencyGraph) CheckCycles() error {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[TaskNode]int)
+	path := make([]TaskNode, 0)
+
+	var visit func(node TaskNode) error
+	visit = func(node TaskNode) error {
+		state[node] = visiting
+		path = append(path, node)
+		for _, ref := range graph.Edges[node] {
+			next, ok := graph.resolve(ref)
+			if !ok {
+				continue
+			}
+			switch state[next] {
+			case visiting:
+				// Found a cycle, report it from the repeated node
+				cycle := make([]string, 0)
+				for idx := len(path) - 1; idx >= 0; idx-- {
+					cycle = append([]string{path[idx].String()}, cycle...)
+					if path[idx] == next {
+						break
+					}
+				}
+				cycle = append(cycle, next.String())
+				return fmt.Errorf("task dependency cycle: %s", strings.Join(cycle, " -> "))
+			case unvisited:
+				if err := visit(next); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[node] = done
+		return nil
+	}
+
+	for _, node := range graph.GetNodes() {
+		if state[node] == unvisited {
+			if err := visit(node); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Returns all the framework-task pairs that the given task depends on,
+// directly or not, sorted.
+func (graph *DependencyGraph) GetDependencies(framework string, task string) []TaskNode {
+	seen := make(map[TaskNode]bool)
+	deps := make([]TaskNode, 0)
+	pending := []TaskNode{{Framework: framework, Task: task}}
+	for len(pending) > 0 {
+		node := pending[0]
+		pending = pending[1:]
+		resolved, ok := graph.resolve(node)
+		if !ok {
+			continue
+		}
+		for _, ref := range graph.Edges[resolved] {
+			if !seen[ref] {
+				seen[ref] = true
+				deps = append(deps, ref)
+				pending = append(pending, ref)
+			}
+		}
+	}
+	sortTaskNodes(deps)
+	return deps
+}
+
+// Returns the dependencies of the given tests that are not part of them. These
+// must be triggered by other workflow calls, otherwise the tests depending on
+// them will never be triggered.
+func (graph *DependencyGraph) GetMissingDependencies(tests []types.TestsData) []TaskNode {
+	inTests := make(map[TaskNode]bool)
+	for _, test := range tests {
+		for _, task := range test.Tasks {
+			inTests[TaskNode{Framework: test.Framework, Task: task}] = true
+		}
+	}
+	seen := make(map[TaskNode]bool)
+	missing := make([]TaskNode, 0)
+	for _, test := range tests {
+		for _, task := range test.Tasks {
+			for _, dep := range graph.GetDependencies(test.Framework, task) {
+				if !inTests[dep] && !seen[dep] {
+					seen[dep] = true
+					missing = append(missing, dep)
+				}
+			}
+		}
+	}
+	sortTaskNodes(missing)
+	return missing
+}
+
+// Returns the graph in DOT format. Tasks resolved through the "any" entry of
+// their framework are linked to it with a dashed edge.
+func (graph *DependencyGraph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph task_dependencies {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+
+	referenced := make(map[TaskNode]bool)
+	for _, node := range graph.GetNodes() {
+		fmt.Fprintf(&sb, "  %q;\n", node.String())
+		for _, ref := range graph.Edges[node] {
+			fmt.Fprintf(&sb, "  %q -> %q;\n", node.String(), ref.String())
+			referenced[ref] = true
+		}
+	}
+
+	refs := make([]TaskNode, 0, len(referenced))
+	for ref := range referenced {
+		refs = append(refs, ref)
+	}
+	sortTaskNodes(refs)
+	for _, ref := range refs {
+		resolved, ok := graph.resolve(ref)
+		if ok && resolved != ref {
+			fmt.Fprintf(&sb, "  %q -> %q [style=dashed, label=\"any\"];\n", ref.String(), resolved.String())
+		}
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func sortTaskNodes(nodes []TaskNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Framework != nodes[j].Framework {
+			return nodes[i].Framework < nodes[j].Framework
+		}
+		return nodes[i].Task < nodes[j].Task
+	})
+}
+
+// Dependency graph of the frameworks configuration, kept when the
+// configuration is validated at startup
+var validatedDependencyGraph *DependencyGraph
+
+// Returns the dependency graph validated at startup
+func GetDependencyGraph() (*DependencyGraph, bool) {
+	return validatedDependencyGraph, validatedDependencyGraph != nil
+}
+
+// Checks that the task dependencies form a valid graph, without dangling
+// references nor cycles. The graph is kept for the workflows (see
+// GetDependencyGraph).
+func ValidateDependencyGraph(configMap map[string]types.FrameworkConfig) error {
+	graph, err := BuildDependencyGraph(configMap)
+	if err != nil {
+		return err
+	}
+	err = graph.CheckCycles()
+	if err != nil {
+		return err
+	}
+	validatedDependencyGraph = graph
+	return nil
+}
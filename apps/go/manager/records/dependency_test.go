@@ -0,0 +1,38 @@
+package records
+
+import (
+	"manager/types"
+	"testing"
+)
+
+func TestValidateRuleReferences(t *testing.T) {
+	configMap := map[string]types.FrameworkConfig{
+		"lmeh":       {TasksTypes: map[string]string{"any": NumericalTaskTypeName}},
+		"signatures": {TasksTypes: map[string]string{"any": SignatureTaskTypeName}},
+	}
+	equal := func(framework string) types.DependencyRule {
+		return types.DependencyRule{Framework: framework, Task: "t", Status: types.DependencyStatusEqual, Value: types.RuleValue{Text: "abc"}}
+	}
+
+	tests := []struct {
+		name    string
+		rule    types.DependencyRule
+		wantErr bool
+	}{
+		{name: "metric of the task type", rule: types.DependencyRule{Framework: "lmeh", Task: "t", Metric: "mean_score", Op: types.RuleOpGte, Value: types.RuleValue{Number: 0.5, IsNumber: true}}},
+		{name: "unknown metric", rule: types.DependencyRule{Framework: "signatures", Task: "t", Metric: "mean_score", Op: types.RuleOpGte, Value: types.RuleValue{Number: 0.5, IsNumber: true}}, wantErr: true},
+		{name: "unknown framework", rule: types.DependencyRule{Framework: "helm", Task: "t", Status: types.DependencyStatusOK}, wantErr: true},
+		{name: "equal on signatures", rule: equal("signatures")},
+		{name: "equal on numerical tasks", rule: equal("lmeh"), wantErr: true},
+		{name: "equal in a group", rule: types.DependencyRule{Any: []types.DependencyRule{equal("signatures"), equal("lmeh")}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRuleReferences(tt.rule, configMap)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
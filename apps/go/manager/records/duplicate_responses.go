@@ -0,0 +1,244 @@
+package records
+
+import (
+	"context"
+	"crypto/sha256"
+	"manager/types"
+	"packages/mongodb"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//------------------------------------------------------------------------------
+// Duplicate responses across suppliers
+//------------------------------------------------------------------------------
+
+// Default parameters of the duplicate responses search
+const (
+	DuplicatesDefaultLookbackHours     uint    = 24
+	DuplicatesDefaultMinSharedDocs     uint    = 10
+	DuplicatesDefaultMinMatchRate      float64 = 0.8
+	DuplicatesDefaultMinResponseLength uint    = 16
+)
+
+// Minimum Jaccard similarity of the words of two normalized responses to
+// consider them near-identical
+const NearDuplicateMinJaccard float64 = 0.9
+
+// DB entry comparing the responses of two suppliers to the same prompts. There
+// is one entry per pair of suppliers, SupplierA < SupplierB.
+type SupplierDuplicatesRecord struct {
+	SupplierA string `bson:"supplier_a"`
+	SupplierB string `bson:"supplier_b"`
+	// Prompts (task and doc_id) answered by both suppliers
+	SharedDocs int `bson:"shared_docs"`
+	// Byte-identical responses
+	ExactMatches int `bson:"exact_matches"`
+	// Identical or near-identical responses (includes the exact matches)
+	NearMatches int     `bson:"near_matches"`
+	MatchRate   float64 `bson:"match_rate"`
+	// Tasks with at least one near match
+	Tasks []string `bson:"tasks"`
+	// Likely a shared backend or replayed responses
+	Flagged bool      `bson:"flagged"`
+	Since   time.Time `bson:"since"`
+	Date    time.Time `bson:"date"`
+}
+
+// A tracked response prepared for the comparison
+type comparableResponse struct {
+	hash       [sha256.Size]byte
+	normalized string
+	words      map[string]struct{}
+}
+
+type duplicatesDocKey struct {
+	task  string
+	docID int64
+}
+
+type duplicatesPairKey struct {
+	a string
+	b string
+}
+
+// Lowercases the response and collapses all whitespace and punctuation, so
+// formatting differences do not hide a shared backend
+func normalizeResponse(response string) string {
+	fields := strings.FieldsFunc(strings.ToLower(response), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+func newComparableResponse(response string) comparableResponse {
+	normalized := normalizeResponse(response)
+	words := make(map[string]struct{})
+	for _, word := range strings.Fields(normalized) {
+		words[word] = struct{}{}
+	}
+	return comparableResponse{
+		hash:       sha256.Sum256([]byte(response)),
+		normalized: normalized,
+		words:      words,
+	}
+}
+
+// Returns whether two responses are byte-identical and whether they are
+// identical or near-identical
+func compareResponses(a comparableResponse, b comparableResponse) (exact bool, near bool) {
+	if a.hash == b.hash {
+		return true, true
+	}
+	if a.normalized == b.normalized {
+		return false, true
+	}
+	intersection := 0
+	for word := range a.words {
+		if _, ok := b.words[word]; ok {
+			intersection++
+		}
+	}
+	union := len(a.words) + len(b.words) - intersection
+	if union == 0 {
+		return false, false
+	}
+	return false, float64(intersection)/float64(union) >= NearDuplicateMinJaccard
+}
+
+// Compares the tracked samples of all suppliers taken since the given date,
+// pairing the responses of different suppliers to the same task and doc_id.
+// Only the last response of each supplier to each prompt is used.
+func FindDuplicateResponses(params types.FindDuplicateResponsesParams, mongoDB mongodb.MongoDb, l *zerolog.Logger) (pairs []SupplierDuplicatesRecord, samples int, err error) {
+
+	// Get the tracked samples, without the prompts
+	trackedCollection := mongoDB.GetCollection(types.TackedTaskSamplesCollection)
+	sample_filter := bson.D{{Key: "sample_date", Value: bson.D{{Key: "$gte", Value: params.Since}}}}
+	opts := options.Find().
+		SetProjection(bson.D{{Key: "prompt", Value: 0}}).
+		SetSort(bson.D{{Key: "sample_date", Value: 1}})
+	ctxM, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	cursor, err := trackedCollection.Find(ctxM, sample_filter, opts)
+	if err != nil {
+		l.Error().Err(err).Msg("Could not retrieve tracked samples from MongoDB.")
+		return nil, 0, err
+	}
+	defer cursor.Close(ctxM)
+
+	// Keep the last response of each supplier to each prompt
+	responses := make(map[duplicatesDocKey]map[string]comparableResponse)
+	for cursor.Next(ctxM) {
+		var sample types.TrackedTaskSample
+		if err = cursor.Decode(&sample); err != nil {
+			l.Error().Err(err).Msg("Could not decode tracked sample from MongoDB.")
+			return nil, 0, err
+		}
+		samples++
+		response := newComparableResponse(sample.Response)
+		if uint(len(response.normalized)) < params.MinResponseLength {
+			continue
+		}
+		key := duplicatesDocKey{task: sample.TaskName, docID: sample.DocID}
+		if _, ok := responses[key]; !ok {
+			responses[key] = make(map[string]comparableResponse)
+		}
+		responses[key][sample.SupplierAddress] = response
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	// Compare the suppliers that answered the same prompt
+	now := time.Now().UTC()
+	pairRecords := make(map[duplicatesPairKey]*SupplierDuplicatesRecord)
+	tasks := make(map[duplicatesPairKey]map[string]bool)
+	for key, bySupplier := range responses {
+		suppliers := make([]string, 0, len(bySupplier))
+		for supplier := range bySupplier {
+			suppliers = append(suppliers, supplier)
+		}
+		sort.Strings(suppliers)
+		for i := 0; i < len(suppliers); i++ {
+			for j := i + 1; j < len(suppliers); j++ {
+				pairKey := duplicatesPairKey{a: suppliers[i], b: suppliers[j]}
+				record, ok := pairRecords[pairKey]
+				if !ok {
+					record = &SupplierDuplicatesRecord{SupplierA: pairKey.a, SupplierB: pairKey.b, Since: params.Since, Date: now}
+					pairRecords[pairKey] = record
+					tasks[pairKey] = make(map[string]bool)
+				}
+				record.SharedDocs++
+				exact, near := compareResponses(bySupplier[pairKey.a], bySupplier[pairKey.b])
+				if exact {
+					record.ExactMatches++
+				}
+				if near {
+					record.NearMatches++
+					tasks[pairKey][key.task] = true
+				}
+			}
+		}
+	}
+
+	// Keep the pairs with enough shared prompts
+	pairs = make([]SupplierDuplicatesRecord, 0)
+	for pairKey, record := range pairRecords {
+		if uint(record.SharedDocs) < params.MinSharedDocs {
+			continue
+		}
+		record.MatchRate = float64(record.NearMatches) / float64(record.SharedDocs)
+		record.Flagged = record.MatchRate >= params.MinMatchRate
+		record.Tasks = make([]string, 0, len(tasks[pairKey]))
+		for task := range tasks[pairKey] {
+			record.Tasks = append(record.Tasks, task)
+		}
+		sort.Strings(record.Tasks)
+		pairs = append(pairs, *record)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].SupplierA != pairs[j].SupplierA {
+			return pairs[i].SupplierA < pairs[j].SupplierA
+		}
+		return pairs[i].SupplierB < pairs[j].SupplierB
+	})
+
+	return pairs, samples, nil
+}
+
+// Deletes the pairs not found by the search made at the given date, so pairs
+// that no longer share docs (or match) do not keep their old flag
+func DeleteStaleDuplicates(searchDate time.Time, mongoDB mongodb.MongoDb, l *zerolog.Logger) (int64, error) {
+	duplicatesCollection := mongoDB.GetCollection(types.SupplierDuplicatesCollection)
+	pair_filter := bson.D{{Key: "date", Value: bson.D{{Key: "$lt", Value: searchDate}}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	response, err := duplicatesCollection.DeleteMany(ctxM, pair_filter)
+	if err != nil {
+		l.Error().Err(err).Msg("Could not delete stale supplier duplicates from MongoDB.")
+		return 0, err
+	}
+	return response.DeletedCount, nil
+}
+
+// Saves the comparison of a pair of suppliers, replacing the previous one
+func (record *SupplierDuplicatesRecord) Save(mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	duplicatesCollection := mongoDB.GetCollection(types.SupplierDuplicatesCollection)
+	pair_filter := bson.D{{Key: "supplier_a", Value: record.SupplierA}, {Key: "supplier_b", Value: record.SupplierB}}
+	update := bson.D{{Key: "$set", Value: record}}
+	opts := options.Update().SetUpsert(true)
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err := duplicatesCollection.UpdateOne(ctxM, pair_filter, update, opts)
+	if err != nil {
+		l.Error().Err(err).Str("supplier_a", record.SupplierA).Str("supplier_b", record.SupplierB).Msg("Could not save supplier duplicates to MongoDB.")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+)
+
+// ------------------------------------------------------------------------------
+// Sample status code classes
+// ------------------------------------------------------------------------------
+
+// Classes of the status code of a sample
+const (
+	// The sample is correct
+	ErrorCodeClassOk string = "ok"
+	// The error is imputable to the supplier, it counts for the error rate
+	ErrorCodeClassPunishable string = "punishable"
+	// The error does not say anything about the supplier quality (i.e. a
+	// timeout), it only counts for the availability
+	ErrorCodeClassNeutral string = "neutral"
+	// The sample is not stored
+	ErrorCodeClassIgnored string = "ignored"
+)
+
+// Classes used when the framework does not configure `error_codes`. Only the
+// errors of the supplier response are stored, the rest are ignored to avoid
+// polluting the buffer with information that is not important to the supplier.
+var DefaultErrorCodes = types.ErrorCodesConfig{
+	Punishable: []int{RelayResponseCodes.Supplier, RelayResponseCodes.Evaluation},
+	Neutral:    []int{},
+	Ignored:    []int{},
+}
+
+// Returns the class of a sample status code
+func GetErrorCodeClass(errorCodes *types.ErrorCodesConfig, statusCode int) string {
+	if statusCode == RelayResponseCodes.Ok {
+		return ErrorCodeClassOk
+	}
+	if errorCodes == nil {
+		errorCodes = &DefaultErrorCodes
+	}
+	for _, code := range errorCodes.Punishable {
+		if code == statusCode {
+			return ErrorCodeClassPunishable
+		}
+	}
+	for _, code := range errorCodes.Neutral {
+		if code == statusCode {
+			return ErrorCodeClassNeutral
+		}
+	}
+	return ErrorCodeClassIgnored
+}
+
+// Returns the class of a sample status code for this task
+func (record *BaseTaskRecord) GetErrorCodeClass(statusCode int) string {
+	return GetErrorCodeClass(record.BufferConfig.ErrorCodes, statusCode)
+}
+
+// Returns the fraction of samples that got a response (correct or not), given
+// the number of correct, punishable and neutral samples in the buffer
+func calculateAvailability(ok int, punishable int, neutral int) float32 {
+	total := ok + punishable + neutral
+	if total == 0 {
+		return 0.0
+	}
+	return float32(ok+punishable) / float32(total)
+}
+
+func validateErrorCodes(errorCodes *types.ErrorCodesConfig) error {
+	if errorCodes == nil {
+		return nil
+	}
+	seen := make(map[int]string)
+	classes := []struct {
+		name  string
+		codes []int
+	}{
+		{ErrorCodeClassPunishable, errorCodes.Punishable},
+		{ErrorCodeClassNeutral, errorCodes.Neutral},
+		{ErrorCodeClassIgnored, errorCodes.Ignored},
+	}
+	for _, class := range classes {
+		for _, code := range class.codes {
+			if code <= 0 {
+				return fmt.Errorf("error_codes %s: invalid status code %d", class.name, code)
+			}
+			if other, ok := seen[code]; ok {
+				return fmt.Errorf("error_codes: status code %d is both %s and %s", code, other, class.name)
+			}
+			seen[code] = class.name
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// ------------------------------------------------------------------------------
+// Evaluation status policies
+// ------------------------------------------------------------------------------
+
+// Actions taken when the evaluator returns a result with non-zero status
+const (
+	// Evaluate the task again (up to the maximum number of retries)
+	EvaluationPolicyRetry string = "retry"
+	// The failure is caused by the supplier (i.e. a response that cannot be
+	// evaluated)
+	EvaluationPolicySupplier string = "supplier"
+	// The failure is caused by the evaluator or the testbench infrastructure
+	EvaluationPolicyEvaluator string = "evaluator"
+	// Nothing is counted, the task is just dropped
+	EvaluationPolicyIgnore string = "ignore"
+)
+
+// Default maximum number of evaluation retries of a task
+const DefaultMaxEvaluationRetries uint32 = 3
+
+// Policies used when the framework does not configure the status, nor "any".
+// Statuses not listed here are ignored, as they were before the policies.
+var defaultEvaluationStatusPolicy = map[int]string{
+	RelayResponseCodes.Supplier:         EvaluationPolicySupplier,
+	RelayResponseCodes.Evaluation:       EvaluationPolicySupplier,
+	RelayResponseCodes.BadParams:        EvaluationPolicyEvaluator,
+	RelayResponseCodes.PromptNotFound:   EvaluationPolicyEvaluator,
+	RelayResponseCodes.DatabaseRead:     EvaluationPolicyEvaluator,
+	RelayResponseCodes.MinerSignerError: EvaluationPolicyRetry, // Used by the evaluator when it fails to process
+}
+
+// Returns the action to take for a non-zero result status of a framework. The
+// specific status entry has priority, then the "any" entry and finally the
+// defaults.
+func GetEvaluationStatusPolicy(framework string, status uint32, configMap map[string]types.FrameworkConfig) string {
+	frameworkCfg, ok := configMap[framework]
+	if ok {
+		if policy, ok := frameworkCfg.EvaluationStatusPolicy[strconv.FormatUint(uint64(status), 10)]; ok {
+			return policy
+		}
+		if policy, ok := frameworkCfg.EvaluationStatusPolicy["any"]; ok {
+			return policy
+		}
+	}
+	if policy, ok := defaultEvaluationStatusPolicy[int(status)]; ok {
+		return policy
+	}
+	return EvaluationPolicyIgnore
+}
+
+// Error string of the samples of the evaluations failed by the supplier
+const EvaluationFailureErrorString string = "evaluation failed"
+
+// Inserts a failed sample in the task buffer for an evaluation that failed
+// because of the supplier, so it counts for the error rate of the task like
+// any other wrong response.
+func InsertEvaluationFailureSample(task TaskInterface, date time.Time, l *zerolog.Logger) error {
+	var sample interface{}
+	switch task.(type) {
+	case *NumericalTaskRecord, *DistributionTaskRecord:
+		sample = ScoresSample{StatusCode: RelayResponseCodes.Evaluation, ErrorString: EvaluationFailureErrorString}
+	case *SignatureTaskRecord:
+		sample = SignatureSample{StatusCode: RelayResponseCodes.Evaluation, ErrorString: EvaluationFailureErrorString}
+	default:
+		return fmt.Errorf("evaluation failure samples not supported by task type %T", task)
+	}
+	_, err := task.InsertSample(date, sample, l)
+	return err
+}
+
+// Returns the maximum number of evaluation retries of the tasks of a framework
+func GetMaxEvaluationRetries(framework string, configMap map[string]types.FrameworkConfig) uint32 {
+	frameworkCfg, ok := configMap[framework]
+	if !ok || frameworkCfg.MaxEvaluationRetries == 0 {
+		return DefaultMaxEvaluationRetries
+	}
+	return frameworkCfg.MaxEvaluationRetries
+}
+
+// Checks the evaluation status policies of all frameworks
+func ValidateEvaluationPolicies(configMap map[string]types.FrameworkConfig) error {
+	for framework, frameworkCfg := range configMap {
+		for status, policy := range frameworkCfg.EvaluationStatusPolicy {
+			if status != "any" {
+				if _, err := strconv.ParseUint(status, 10, 32); err != nil {
+					return fmt.Errorf("framework %s: evaluation_status_policy key %q must be a status code or \"any\"", framework, status)
+				}
+			}
+			switch policy {
+			case EvaluationPolicyRetry, EvaluationPolicySupplier, EvaluationPolicyEvaluator, EvaluationPolicyIgnore:
+			default:
+				return fmt.Errorf("framework %s: evaluation_status_policy %s: unknown policy %q (expected %s, %s, %s or %s)",
+					framework, status, policy,
+					EvaluationPolicyRetry, EvaluationPolicySupplier, EvaluationPolicyEvaluator, EvaluationPolicyIgnore)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package records
+
+import (
+	"manager/types"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestInsertEvaluationFailureSample(t *testing.T) {
+	record := &NumericalTaskRecord{}
+	record.TaskData.BufferConfig = types.TaskBufferConfig{CircularBufferLength: 10}
+	record.NewTask(primitive.NewObjectID(), "lmeh", "task", types.EpochStart, &nopLogger)
+	date := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := record.InsertSample(date, ScoresSample{Score: 1}, &nopLogger); err != nil {
+		t.Fatal(err)
+	}
+	if err := InsertEvaluationFailureSample(record, date.Add(time.Hour), &nopLogger); err != nil {
+		t.Fatal(err)
+	}
+	if err := record.ProcessData(&nopLogger); err != nil {
+		t.Fatal(err)
+	}
+	// The failure counts against the supplier, as a wrong response
+	if !floatEqual(float64(record.ErrorRate), 0.5) {
+		t.Errorf("error rate %v, want 0.5", record.ErrorRate)
+	}
+}
@@ -0,0 +1,65 @@
+package records
+
+import (
+	"context"
+	"manager/types"
+	"packages/mongodb"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//------------------------------------------------------------------------------
+// SupplierEvent
+//------------------------------------------------------------------------------
+
+// Types of supplier events
+const (
+	// A signature never seen before for this supplier and task
+	SupplierEventSignatureChange string = "signature_change"
+	// A significant drop of the mean score of a numerical task
+	SupplierEventScoreRegression string = "score_regression"
+)
+
+// DB entry of a notable change detected on a supplier. These are only written,
+// the manager does not act on them.
+type SupplierEvent struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty"`
+	SupplierID primitive.ObjectID     `bson:"supplier_id"`
+	Framework  string                 `bson:"framework"`
+	Task       string                 `bson:"task"`
+	EventType  string                 `bson:"event_type"`
+	Date       time.Time              `bson:"date"`
+	Height     int64                  `bson:"height"`
+	Details    map[string]interface{} `bson:"details"`
+}
+
+// Task records that produce supplier events while processing their data. The
+// events are kept in the record until they are popped and saved.
+type EventEmitter interface {
+	PopEvents() []SupplierEvent
+}
+
+// Saves the given events in the supplier events collection
+func SaveSupplierEvents(events []SupplierEvent, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	eventsCollection := mongoDB.GetCollection(types.SupplierEventsCollection)
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	docs := make([]interface{}, len(events))
+	for i := range events {
+		docs[i] = events[i]
+	}
+	_, err := eventsCollection.InsertMany(ctxM, docs)
+	if err != nil {
+		l.Error().Err(err).Int("events", len(events)).Msg("Could not save supplier events to MongoDB.")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,530 @@
+package records
+
+import (
+	"context"
+	"manager/types"
+	"math"
+	"packages/mongodb"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//------------------------------------------------------------------------------
+// Supplier backend fingerprints
+//------------------------------------------------------------------------------
+
+// Default parameters of the backend clustering
+const (
+	FingerprintDefaultLookbackHours uint    = 72
+	FingerprintDefaultMinSimilarity float64 = 0.85
+	FingerprintDefaultMinComponents uint    = 2
+)
+
+// Names of the similarity components between two suppliers
+const (
+	FingerprintSignatures string = "signatures"
+	FingerprintLatency    string = "latency"
+	FingerprintErrors     string = "errors"
+	FingerprintFormat     string = "format"
+	FingerprintDuplicates string = "duplicates"
+)
+
+// Weight of each similarity component. Signatures are weak evidence (all the
+// suppliers running the same model share them), identical responses are the
+// strongest.
+var fingerprintWeights = map[string]float64{
+	FingerprintSignatures: 0.5,
+	FingerprintLatency:    2.0,
+	FingerprintErrors:     2.0,
+	FingerprintFormat:     1.0,
+	FingerprintDuplicates: 3.0,
+}
+
+// Components that can tell apart two suppliers running the same model on
+// similar hardware. Signatures and latency are shared by those suppliers, so
+// they do not count towards the minimum components and a link requires at
+// least one of these.
+var fingerprintStrongComponents = []string{
+	FingerprintErrors,
+	FingerprintFormat,
+	FingerprintDuplicates,
+}
+
+// Minimum number of tasks measured on both suppliers to compare their latencies
+const fingerprintMinLatencyTasks int = 3
+
+// Minimum number of tracked responses to calculate the formatting features
+const fingerprintMinFormatSamples int = 10
+
+// Features of a supplier that can identify its backend
+type supplierFingerprint struct {
+	supplier SupplierRecord
+	// Signature by framework:task
+	signatures map[string]string
+	// Median run time by framework:task
+	latencies map[string]float64
+	// Normalized error strings of the samples in the buffers
+	errors map[string]struct{}
+	// Response formatting features, see formatFeatures
+	format        []float64
+	formatSamples int
+}
+
+// DB entry of a group of suppliers believed to share one backend
+type SupplierBackendGroup struct {
+	ID        primitive.ObjectID   `bson:"_id,omitempty"`
+	Suppliers []BackendGroupMember `bson:"suppliers"`
+	Links     []BackendGroupLink   `bson:"links"`
+	Date      time.Time            `bson:"date"`
+	Since     time.Time            `bson:"since"`
+}
+
+type BackendGroupMember struct {
+	SupplierID primitive.ObjectID `bson:"supplier_id"`
+	Address    string             `bson:"address"`
+	Service    string             `bson:"service"`
+}
+
+// A pair of suppliers of the group that are similar enough to be linked
+type BackendGroupLink struct {
+	SupplierA  primitive.ObjectID `bson:"supplier_a"`
+	SupplierB  primitive.ObjectID `bson:"supplier_b"`
+	Similarity float64            `bson:"similarity"`
+	Components map[string]float64 `bson:"components"`
+}
+
+var errorNumbersRegex = regexp.MustCompile(`(0x)?[0-9a-f]*[0-9][0-9a-f]*`)
+
+// Lowercases an error string and replaces numbers (ids, ports, heights, etc.)
+// so the same error from the same backend gives the same string
+func normalizeErrorString(errorString string) string {
+	normalized := errorNumbersRegex.ReplaceAllString(strings.ToLower(strings.TrimSpace(errorString)), "#")
+	if len(normalized) > 256 {
+		normalized = normalized[:256]
+	}
+	return normalized
+}
+
+// Returns the formatting features of a response: length (log scale), lines,
+// markdown usage and leading/trailing whitespace
+func formatFeatures(response string) []float64 {
+	features := make([]float64, 6)
+	features[0] = math.Log1p(float64(len(response))) / 10
+	features[1] = math.Log1p(float64(strings.Count(response, "\n"))) / 5
+	if strings.Contains(response, "```") {
+		features[2] = 1
+	}
+	if strings.Contains(response, "**") || strings.Contains(response, "\n- ") || strings.Contains(response, "\n* ") {
+		features[3] = 1
+	}
+	if len(response) > 0 && strings.TrimLeft(response, " \t\n") != response {
+		features[4] = 1
+	}
+	if len(response) > 0 && strings.TrimRight(response, " \t\n") != response {
+		features[5] = 1
+	}
+	return features
+}
+
+// Loads the fingerprints of all suppliers from their buffers and the tracked
+// samples taken since the given date
+func loadSupplierFingerprints(since time.Time, mongoDB mongodb.MongoDb, l *zerolog.Logger) (map[primitive.ObjectID]*supplierFingerprint, error) {
+
+	fingerprints := make(map[primitive.ObjectID]*supplierFingerprint)
+	byAddress := make(map[string][]*supplierFingerprint)
+
+	// Suppliers
+	suppliersCollection := mongoDB.GetCollection(types.SuppliersCollection)
+	ctxM, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	cursor, err := suppliersCollection.Find(ctxM, bson.D{})
+	if err != nil {
+		l.Error().Err(err).Msg("Could not retrieve suppliers from MongoDB.")
+		return nil, err
+	}
+	var suppliers []SupplierRecord
+	if err = cursor.All(ctxM, &suppliers); err != nil {
+		l.Error().Err(err).Msg("Could not decode suppliers from MongoDB.")
+		return nil, err
+	}
+	for _, supplier := range suppliers {
+		fingerprint := &supplierFingerprint{
+			supplier:   supplier,
+			signatures: make(map[string]string),
+			latencies:  make(map[string]float64),
+			errors:     make(map[string]struct{}),
+			format:     make([]float64, len(formatFeatures(""))),
+		}
+		fingerprints[supplier.ID] = fingerprint
+		byAddress[supplier.Address] = append(byAddress[supplier.Address], fingerprint)
+	}
+
+	// Task buffers
+	for _, taskType := range GetRegisteredTaskTypes() {
+		registration, _ := GetTaskTypeRegistration(taskType)
+		tasksCollection := mongoDB.GetCollection(registration.Collection)
+		cursor, err := tasksCollection.Find(ctxM, bson.D{})
+		if err != nil {
+			l.Error().Err(err).Str("task_type", taskType).Msg("Could not retrieve tasks from MongoDB.")
+			return nil, err
+		}
+		for cursor.Next(ctxM) {
+			task := registration.NewTask()
+			if err := cursor.Decode(task); err != nil {
+				l.Error().Err(err).Str("task_type", taskType).Msg("Could not decode task from MongoDB.")
+				cursor.Close(ctxM)
+				return nil, err
+			}
+			fingerprint, ok := fingerprints[task.GetSupplierID()]
+			if !ok {
+				continue
+			}
+			fingerprint.addTask(task)
+		}
+		err = cursor.Err()
+		cursor.Close(ctxM)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tracked responses formatting
+	trackedCollection := mongoDB.GetCollection(types.TackedTaskSamplesCollection)
+	sample_filter := bson.D{{Key: "sample_date", Value: bson.D{{Key: "$gte", Value: since}}}}
+	opts := options.Find().SetProjection(bson.D{{Key: "supplier_address", Value: 1}, {Key: "response", Value: 1}})
+	cursor, err = trackedCollection.Find(ctxM, sample_filter, opts)
+	if err != nil {
+		l.Error().Err(err).Msg("Could not retrieve tracked samples from MongoDB.")
+		return nil, err
+	}
+	defer cursor.Close(ctxM)
+	for cursor.Next(ctxM) {
+		var sample types.TrackedTaskSample
+		if err := cursor.Decode(&sample); err != nil {
+			l.Error().Err(err).Msg("Could not decode tracked sample from MongoDB.")
+			return nil, err
+		}
+		features := formatFeatures(sample.Response)
+		for _, fingerprint := range byAddress[sample.SupplierAddress] {
+			for i := range features {
+				fingerprint.format[i] += features[i]
+			}
+			fingerprint.formatSamples++
+		}
+	}
+	for _, fingerprint := range fingerprints {
+		if fingerprint.formatSamples > 0 {
+			for i := range fingerprint.format {
+				fingerprint.format[i] /= float64(fingerprint.formatSamples)
+			}
+		}
+	}
+
+	return fingerprints, cursor.Err()
+}
+
+// Adds the features of a task buffer to the fingerprint
+func (fingerprint *supplierFingerprint) addTask(task TaskInterface) {
+	key := task.GetFramework() + ":" + task.GetTask()
+	var numerical *NumericalTaskRecord
+	switch record := task.(type) {
+	case *NumericalTaskRecord:
+		numerical = record
+	case *DistributionTaskRecord:
+		numerical = &record.NumericalTaskRecord
+	case *SignatureTaskRecord:
+		if record.LastSignature != "" {
+			fingerprint.signatures[key] = record.LastSignature
+		}
+		for _, sample := range record.Signatures {
+			if sample.ErrorString != "" {
+				fingerprint.errors[normalizeErrorString(sample.ErrorString)] = struct{}{}
+			}
+		}
+	}
+	if numerical != nil {
+		if numerical.GetNumOkSamples() > 0 {
+			fingerprint.latencies[key] = float64(numerical.MedianProcessTime)
+		}
+		for _, sample := range numerical.ScoresSamples {
+			if sample.ErrorString != "" {
+				fingerprint.errors[normalizeErrorString(sample.ErrorString)] = struct{}{}
+			}
+		}
+	}
+}
+
+// Calculates the similarity components of two fingerprints, only the
+// components with data on both suppliers are returned
+func compareFingerprints(a *supplierFingerprint, b *supplierFingerprint, duplicates map[duplicatesPairKey]float64) map[string]float64 {
+	components := make(map[string]float64)
+
+	// Signatures, fraction of the shared tasks with the same signature
+	shared, equal := 0, 0
+	for key, signature := range a.signatures {
+		if other, ok := b.signatures[key]; ok {
+			shared++
+			if other == signature {
+				equal++
+			}
+		}
+	}
+	if shared > 0 {
+		components[FingerprintSignatures] = float64(equal) / float64(shared)
+	}
+
+	// Latency, mean relative similarity of the shared tasks median run time
+	shared = 0
+	latencySum := 0.0
+	for key, latency := range a.latencies {
+		other, ok := b.latencies[key]
+		if !ok {
+			continue
+		}
+		shared++
+		if maxLatency := math.Max(latency, other); maxLatency > 0 {
+			latencySum += 1 - math.Abs(latency-other)/maxLatency
+		} else {
+			latencySum += 1
+		}
+	}
+	if shared >= fingerprintMinLatencyTasks {
+		components[FingerprintLatency] = latencySum / float64(shared)
+	}
+
+	// Errors, Jaccard similarity of the error strings
+	if len(a.errors) > 0 && len(b.errors) > 0 {
+		intersection := 0
+		for errorString := range a.errors {
+			if _, ok := b.errors[errorString]; ok {
+				intersection++
+			}
+		}
+		components[FingerprintErrors] = float64(intersection) / float64(len(a.errors)+len(b.errors)-intersection)
+	}
+
+	// Formatting, one minus the mean absolute difference of the features
+	if a.formatSamples >= fingerprintMinFormatSamples && b.formatSamples >= fingerprintMinFormatSamples {
+		diff := 0.0
+		for i := range a.format {
+			diff += math.Abs(a.format[i] - b.format[i])
+		}
+		components[FingerprintFormat] = clamp01(1 - diff/float64(len(a.format)))
+	}
+
+	// Identical responses, from the duplicate responses search
+	pairKey := duplicatesPairKey{a: a.supplier.Address, b: b.supplier.Address}
+	if pairKey.b < pairKey.a {
+		pairKey.a, pairKey.b = pairKey.b, pairKey.a
+	}
+	if matchRate, ok := duplicates[pairKey]; ok {
+		components[FingerprintDuplicates] = matchRate
+	}
+
+	return components
+}
+
+// Weighted mean of the similarity components
+func fingerprintSimilarity(components map[string]float64) float64 {
+	var weightedSum, totalWeight float64
+	for name, value := range components {
+		weightedSum += fingerprintWeights[name] * value
+		totalWeight += fingerprintWeights[name]
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+	return weightedSum / totalWeight
+}
+
+// Returns the number of components that count towards the minimum (all but
+// the signatures) and how many of them are strong
+func countFingerprintComponents(components map[string]float64) (counted uint, strong uint) {
+	for name := range components {
+		if name != FingerprintSignatures {
+			counted++
+		}
+	}
+	for _, name := range fingerprintStrongComponents {
+		if _, ok := components[name]; ok {
+			strong++
+		}
+	}
+	return counted, strong
+}
+
+// Loads the match rates of the duplicate responses searches made since the
+// given date
+func loadDuplicatesMatchRates(since time.Time, mongoDB mongodb.MongoDb, l *zerolog.Logger) (map[duplicatesPairKey]float64, error) {
+	duplicatesCollection := mongoDB.GetCollection(types.SupplierDuplicatesCollection)
+	pair_filter := bson.D{{Key: "date", Value: bson.D{{Key: "$gte", Value: since}}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	cursor, err := duplicatesCollection.Find(ctxM, pair_filter)
+	if err != nil {
+		l.Error().Err(err).Msg("Could not retrieve supplier duplicates from MongoDB.")
+		return nil, err
+	}
+	var pairs []SupplierDuplicatesRecord
+	if err = cursor.All(ctxM, &pairs); err != nil {
+		l.Error().Err(err).Msg("Could not decode supplier duplicates from MongoDB.")
+		return nil, err
+	}
+	matchRates := make(map[duplicatesPairKey]float64, len(pairs))
+	for _, pair := range pairs {
+		matchRates[duplicatesPairKey{a: pair.SupplierA, b: pair.SupplierB}] = pair.MatchRate
+	}
+	return matchRates, nil
+}
+
+// Union-find over the supplier indexes
+type supplierUnionFind struct {
+	parent []int
+}
+
+func newSupplierUnionFind(n int) *supplierUnionFind {
+	uf := supplierUnionFind{parent: make([]int, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return &uf
+}
+
+func (uf *supplierUnionFind) find(i int) int {
+	for uf.parent[i] != i {
+		uf.parent[i] = uf.parent[uf.parent[i]]
+		i = uf.parent[i]
+	}
+	return i
+}
+
+func (uf *supplierUnionFind) union(i int, j int) {
+	rootI, rootJ := uf.find(i), uf.find(j)
+	if rootI != rootJ {
+		uf.parent[rootJ] = rootI
+	}
+}
+
+// Clusters the suppliers by their backend fingerprints. Two suppliers are
+// linked if they have enough similarity components, at least one of them
+// strong (see fingerprintStrongComponents), and their similarity is large
+// enough, the groups are the connected components with more than one
+// supplier.
+func ClusterSupplierBackends(params types.ClusterBackendsParams, mongoDB mongodb.MongoDb, l *zerolog.Logger) (groups []SupplierBackendGroup, numSuppliers int, err error) {
+
+	fingerprints, err := loadSupplierFingerprints(params.Since, mongoDB, l)
+	if err != nil {
+		return nil, 0, err
+	}
+	duplicates, err := loadDuplicatesMatchRates(params.Since, mongoDB, l)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Sort the suppliers, so the output does not depend on the map order
+	ordered := make([]*supplierFingerprint, 0, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		ordered = append(ordered, fingerprint)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].supplier.ID.Hex() < ordered[j].supplier.ID.Hex()
+	})
+
+	// Link the similar suppliers
+	uf := newSupplierUnionFind(len(ordered))
+	links := make([]BackendGroupLink, 0)
+	for i := 0; i < len(ordered); i++ {
+		for j := i + 1; j < len(ordered); j++ {
+			if ordered[i].supplier.Address == ordered[j].supplier.Address {
+				// The same supplier in another service
+				continue
+			}
+			components := compareFingerprints(ordered[i], ordered[j], duplicates)
+			counted, strong := countFingerprintComponents(components)
+			if counted < params.MinComponents || strong == 0 {
+				continue
+			}
+			similarity := fingerprintSimilarity(components)
+			if similarity < params.MinSimilarity {
+				continue
+			}
+			uf.union(i, j)
+			links = append(links, BackendGroupLink{
+				SupplierA:  ordered[i].supplier.ID,
+				SupplierB:  ordered[j].supplier.ID,
+				Similarity: similarity,
+				Components: components,
+			})
+		}
+	}
+
+	// Build the groups
+	now := time.Now().UTC()
+	groupIdx := make(map[int]int)
+	for i, fingerprint := range ordered {
+		root := uf.find(i)
+		idx, ok := groupIdx[root]
+		if !ok {
+			idx = len(groups)
+			groupIdx[root] = idx
+			groups = append(groups, SupplierBackendGroup{Date: now, Since: params.Since})
+		}
+		groups[idx].Suppliers = append(groups[idx].Suppliers, BackendGroupMember{
+			SupplierID: fingerprint.supplier.ID,
+			Address:    fingerprint.supplier.Address,
+			Service:    fingerprint.supplier.Service,
+		})
+	}
+	supplierIdx := make(map[primitive.ObjectID]int, len(ordered))
+	for i, fingerprint := range ordered {
+		supplierIdx[fingerprint.supplier.ID] = i
+	}
+	for _, link := range links {
+		idx := groupIdx[uf.find(supplierIdx[link.SupplierA])]
+		groups[idx].Links = append(groups[idx].Links, link)
+	}
+
+	// Only the groups with more than one supplier are relevant
+	result := make([]SupplierBackendGroup, 0)
+	for _, group := range groups {
+		if len(group.Suppliers) > 1 {
+			result = append(result, group)
+		}
+	}
+
+	return result, len(ordered), nil
+}
+
+// Replaces the stored backend groups with the given ones
+func SaveSupplierBackendGroups(groups []SupplierBackendGroup, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	groupsCollection := mongoDB.GetCollection(types.SupplierBackendGroupsCollection)
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	_, err := groupsCollection.DeleteMany(ctxM, bson.D{})
+	if err != nil {
+		l.Error().Err(err).Msg("Could not delete previous supplier backend groups from MongoDB.")
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(groups))
+	for i := range groups {
+		docs[i] = groups[i]
+	}
+	_, err = groupsCollection.InsertMany(ctxM, docs)
+	if err != nil {
+		l.Error().Err(err).Int("groups", len(groups)).Msg("Could not save supplier backend groups to MongoDB.")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"manager/types"
+	"packages/mongodb"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+//------------------------------------------------------------------------------
+// Orphan documents of the task tree
+//------------------------------------------------------------------------------
+
+// Default parameters of the orphans cleanup
+const (
+	OrphansDefaultGraceMinutes uint = 60
+	OrphansDefaultBatchSize    uint = 1000
+	OrphansDefaultMaxBatches   uint = 50
+	// Task IDs given as example in the cleanup reports
+	OrphansReportSampleSize int = 10
+)
+
+// A collection of the task tree and the field that references the task
+type OrphanCollection struct {
+	Name        string
+	TaskIDField string
+}
+
+// Collections that hang from the tasks collection, in the order they are
+// created
+var OrphanCollections = []OrphanCollection{
+	{Name: types.InstanceCollection, TaskIDField: "task_id"},
+	{Name: types.PromptsCollection, TaskIDField: "task_id"},
+	{Name: types.ResponsesCollection, TaskIDField: "task_id"},
+	{Name: types.ResultsCollection, TaskIDField: "result_data.task_id"},
+}
+
+// Returns the task tree collection with the given name
+func GetOrphanCollection(name string) (OrphanCollection, error) {
+	for _, collection := range OrphanCollections {
+		if collection.Name == name {
+			return collection, nil
+		}
+	}
+	return OrphanCollection{}, fmt.Errorf("collection %s is not part of the task tree", name)
+}
+
+// A document whose task no longer exists
+type OrphanDocument struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	TaskID primitive.ObjectID `bson:"task_id"`
+}
+
+// Scans a batch of documents of the collection, in _id order starting after
+// the given one, and returns the ones whose task does not exist. Only the
+// documents created before the given date are scanned. Returns the last
+// scanned document, to continue from it, and the number of scanned documents.
+func FindOrphans(collection OrphanCollection, olderThan time.Time, afterID primitive.ObjectID, limit int64, mongoDB mongodb.MongoDb, l *zerolog.Logger) (orphans []OrphanDocument, lastID primitive.ObjectID, scanned int, err error) {
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "_id", Value: bson.D{
+			{Key: "$gt", Value: afterID},
+			{Key: "$lt", Value: primitive.NewObjectIDFromTimestamp(olderThan)},
+		}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.D{{Key: "task_id", Value: "$" + collection.TaskIDField}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: types.TaskCollection},
+			{Key: "localField", Value: "task_id"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "task"},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "task_id", Value: 1},
+			// Documents without a valid task_id are not touched
+			{Key: "orphan", Value: bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$type", Value: "$task_id"}}, "objectId"}}},
+				bson.D{{Key: "$eq", Value: bson.A{bson.D{{Key: "$size", Value: "$task"}}, 0}}},
+			}}}},
+		}}},
+	}
+
+	docsCollection := mongoDB.GetCollection(collection.Name)
+	ctxM, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	cursor, err := docsCollection.Aggregate(ctxM, pipeline)
+	if err != nil {
+		l.Error().Err(err).Str("collection", collection.Name).Msg("Could not look for orphan documents in MongoDB.")
+		return nil, afterID, 0, err
+	}
+	defer cursor.Close(ctxM)
+
+	lastID = afterID
+	orphans = make([]OrphanDocument, 0)
+	for cursor.Next(ctxM) {
+		var doc struct {
+			OrphanDocument `bson:",inline"`
+			Orphan         bool `bson:"orphan"`
+		}
+		if err = cursor.Decode(&doc); err != nil {
+			l.Error().Err(err).Str("collection", collection.Name).Msg("Could not decode document from MongoDB.")
+			return nil, afterID, 0, err
+		}
+		scanned++
+		lastID = doc.ID
+		if doc.Orphan {
+			orphans = append(orphans, doc.OrphanDocument)
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, afterID, 0, err
+	}
+
+	return orphans, lastID, scanned, nil
+}
+
+// Deletes the given orphan documents. The task of an orphan cannot appear
+// again, so they are deleted by _id without checking them again.
+func DeleteOrphans(collection OrphanCollection, orphans []OrphanDocument, mongoDB mongodb.MongoDb, l *zerolog.Logger) (deleted int64, err error) {
+	if len(orphans) == 0 {
+		return 0, nil
+	}
+	ids := make(bson.A, len(orphans))
+	for i, orphan := range orphans {
+		ids[i] = orphan.ID
+	}
+	docsCollection := mongoDB.GetCollection(collection.Name)
+	orphan_filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	response, err := docsCollection.DeleteMany(ctxM, orphan_filter)
+	if err != nil {
+		l.Error().Err(err).Str("collection", collection.Name).Msg("Could not delete orphan documents from MongoDB.")
+		return 0, err
+	}
+	return response.DeletedCount, nil
+}
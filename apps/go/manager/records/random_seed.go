@@ -0,0 +1,36 @@
+package records
+
+import (
+	"fmt"
+	"hash/fnv"
+	"manager/types"
+)
+
+//------------------------------------------------------------------------------
+// Sampler random seeds
+//------------------------------------------------------------------------------
+
+// Returns the random seed of a trigger, following the seed strategy of the
+// framework. The run seed is the one shared by all the triggers of a manager
+// run. The derived seeds are non-negative 31 bits integers.
+func GetTriggerRandomSeed(strategy string, runSeed int, supplier types.SupplierData, framework string, task string, block types.BlockData) int {
+	switch strategy {
+	case types.SeedStrategySupplier:
+		return deriveRandomSeed(fmt.Sprintf("%d|%s|%s", runSeed, supplier.Address, supplier.Service))
+	case types.SeedStrategyTask:
+		return deriveRandomSeed(fmt.Sprintf("%d|%s|%s|%s|%s", runSeed, supplier.Address, supplier.Service, framework, task))
+	case types.SeedStrategySession:
+		session := block.Height
+		if block.BlocksPerSession > 0 {
+			session = block.Height / block.BlocksPerSession
+		}
+		return deriveRandomSeed(fmt.Sprintf("%s|%s|%d", supplier.Address, supplier.Service, session))
+	}
+	return runSeed
+}
+
+func deriveRandomSeed(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() & 0x7fffffff)
+}
@@ -0,0 +1,149 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+	"sort"
+	"sync"
+)
+
+// ------------------------------------------------------------------------------
+// Task Types Registry
+// ------------------------------------------------------------------------------
+
+// All the information needed to handle a given type of task buffer. Each task
+// type registers itself (usually in an init function) and then it can be used
+// by name in the `task_types` field of the frameworks configuration.
+type TaskTypeRegistration struct {
+	// Name used in the configuration to refer to this task type
+	Name string
+	// MongoDB collection where the task buffers are stored
+	Collection string
+	// Returns a new empty task record of this type
+	NewTask func() TaskInterface
+	// Returns a new empty result record, used to decode the evaluator results
+	NewResult func() ResultInterface
+	// Default buffer sizing, used when the framework config does not set it
+	BufferDefaults types.TaskBufferConfig
+	// Names of the metrics returned by the GetMetric method of the task record,
+	// used to validate the dependency rules
+	Metrics []string
+	// Whether the IsEqual method of the task record is implemented, required
+	// by the "equal" status of the dependency rules
+	SupportsEqual bool
+}
+
+var (
+	taskTypesMutex sync.RWMutex
+	taskTypes      = make(map[string]TaskTypeRegistration)
+)
+
+// Adds a new task type to the registry. Registering the same name twice is an
+// error, as it would silently change how existing buffers are decoded.
+func RegisterTaskType(registration TaskTypeRegistration) error {
+	if registration.Name == "" {
+		return fmt.Errorf("task type name cannot be empty")
+	}
+	if registration.Collection == "" {
+		return fmt.Errorf("task type %s has no collection", registration.Name)
+	}
+	if registration.NewTask == nil || registration.NewResult == nil {
+		return fmt.Errorf("task type %s must provide task and result constructors", registration.Name)
+	}
+	if err := validateBufferConfig(registration.BufferDefaults); err != nil {
+		return fmt.Errorf("task type %s: invalid buffer defaults: %s", registration.Name, err.Error())
+	}
+
+	taskTypesMutex.Lock()
+	defer taskTypesMutex.Unlock()
+
+	if _, exists := taskTypes[registration.Name]; exists {
+		return fmt.Errorf("task type %s already registered", registration.Name)
+	}
+	taskTypes[registration.Name] = registration
+	return nil
+}
+
+// Same as RegisterTaskType but panics on error, to be used in init functions.
+func MustRegisterTaskType(registration TaskTypeRegistration) {
+	if err := RegisterTaskType(registration); err != nil {
+		panic(err)
+	}
+}
+
+// Returns the registration data of a given task type
+func GetTaskTypeRegistration(taskType string) (TaskTypeRegistration, bool) {
+	taskTypesMutex.RLock()
+	defer taskTypesMutex.RUnlock()
+	registration, ok := taskTypes[taskType]
+	return registration, ok
+}
+
+// Returns the names of all registered task types, sorted
+func GetRegisteredTaskTypes() []string {
+	taskTypesMutex.RLock()
+	defer taskTypesMutex.RUnlock()
+	names := make([]string, 0, len(taskTypes))
+	for name := range taskTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the collections used by all registered task types, sorted and without
+// duplicates
+func GetTaskTypesCollections() []string {
+	taskTypesMutex.RLock()
+	defer taskTypesMutex.RUnlock()
+	seen := make(map[string]bool)
+	collections := make([]string, 0, len(taskTypes))
+	for _, registration := range taskTypes {
+		if seen[registration.Collection] {
+			continue
+		}
+		seen[registration.Collection] = true
+		collections = append(collections, registration.Collection)
+	}
+	sort.Strings(collections)
+	return collections
+}
+
+// Returns true if the task type provides the given metric
+func TaskTypeHasMetric(taskType string, metric string) bool {
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		return false
+	}
+	for _, name := range registration.Metrics {
+		if name == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the task type can be used with the "equal" dependency status
+func TaskTypeSupportsEqual(taskType string) bool {
+	registration, ok := GetTaskTypeRegistration(taskType)
+	return ok && registration.SupportsEqual
+}
+
+// Returns a new empty result record for the given task type
+func NewResultRecord(taskType string) (ResultInterface, error) {
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		return nil, fmt.Errorf("task type %s not registered", taskType)
+	}
+	return registration.NewResult(), nil
+}
+
+// Returns the collection of the given task type, or an empty string if the task
+// type is unknown
+func getTaskTypeCollection(taskType string) string {
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		return ""
+	}
+	return registration.Collection
+}
@@ -0,0 +1,233 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"manager/types"
+	"packages/mongodb"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+//------------------------------------------------------------------------------
+// Supplier reputation
+//------------------------------------------------------------------------------
+
+// Names of the reputation components (the taxonomy components are prefixed
+// with ReputationTaxonomyPrefix)
+const (
+	ReputationTaxonomyPrefix string = "taxonomy:"
+	ReputationErrorRate      string = "error_rate"
+	ReputationLatency        string = "latency"
+	ReputationAvailability   string = "availability"
+)
+
+// Composite score of a supplier, stored with its component breakdown
+type SupplierReputation struct {
+	Score      float64                        `bson:"score"`
+	Components map[string]ReputationComponent `bson:"components"`
+	UpdateTime time.Time                      `bson:"update_time"`
+}
+
+// A component of the reputation, the value is in the [0, 1] range
+type ReputationComponent struct {
+	Value  float64 `bson:"value"`
+	Weight float64 `bson:"weight"`
+}
+
+// Calculates the reputation of the supplier from its taxonomy summaries, task
+// buffers and uptime. The uptime must be already updated.
+func (record *SupplierRecord) CalculateReputation(cfg *types.ReputationConfig, mongoDB mongodb.MongoDb, l *zerolog.Logger) (SupplierReputation, error) {
+
+	reputation := SupplierReputation{
+		Components: make(map[string]ReputationComponent),
+		UpdateTime: time.Now().UTC(),
+	}
+
+	// Taxonomies root scores
+	for taxonomy, weight := range cfg.TaxonomyWeights {
+		if weight == 0 {
+			continue
+		}
+		summary, found := GetTaxonomyData(record.ID, taxonomy, mongoDB, l)
+		if !found {
+			continue
+		}
+		root, found := summary.TaxonomyNodesScores[types.DefaultTaxonomyNode]
+		if !found {
+			continue
+		}
+		reputation.Components[ReputationTaxonomyPrefix+taxonomy] = ReputationComponent{Value: clamp01(root.Score), Weight: weight}
+	}
+
+	// Error rate and latency of the task buffers, averaged using the number
+	// of samples of each task
+	if cfg.ErrorRateWeight > 0 || cfg.LatencyWeight > 0 {
+		var errorSum, errorSamples, latencySum, latencySamples float64
+		for _, taskType := range GetRegisteredTaskTypes() {
+			hasErrors := cfg.ErrorRateWeight > 0 && TaskTypeHasMetric(taskType, "error_rate")
+			hasLatency := cfg.LatencyWeight > 0 && TaskTypeHasMetric(taskType, cfg.LatencyMetric)
+			if !hasErrors && !hasLatency {
+				continue
+			}
+			tasks, err := loadSupplierTasks(record, taskType, mongoDB, l)
+			if err != nil {
+				return reputation, err
+			}
+			for _, task := range tasks {
+				numSamples, _ := task.GetMetric("num_samples")
+				if numSamples == 0 {
+					continue
+				}
+				if hasErrors {
+					if value, ok := task.GetMetric("error_rate"); ok {
+						errorSum += value * numSamples
+						errorSamples += numSamples
+					}
+				}
+				if hasLatency && task.GetNumOkSamples() > 0 {
+					if value, ok := task.GetMetric(cfg.LatencyMetric); ok {
+						latencySum += value * numSamples
+						latencySamples += numSamples
+					}
+				}
+			}
+		}
+		if errorSamples > 0 {
+			reputation.Components[ReputationErrorRate] = ReputationComponent{
+				Value:  clamp01(1 - errorSum/errorSamples),
+				Weight: cfg.ErrorRateWeight,
+			}
+		}
+		if latencySamples > 0 {
+			reputation.Components[ReputationLatency] = ReputationComponent{
+				Value:  clamp01(1 - (latencySum/latencySamples)/cfg.MaxLatency),
+				Weight: cfg.LatencyWeight,
+			}
+		}
+	}
+
+	// Availability
+	if cfg.AvailabilityWeight > 0 && !record.UptimeUpdateTime.IsZero() {
+		reputation.Components[ReputationAvailability] = ReputationComponent{
+			Value:  clamp01(record.Uptime7d / 100),
+			Weight: cfg.AvailabilityWeight,
+		}
+	}
+
+	// Weighted mean of the available components
+	var weightedSum, totalWeight float64
+	for _, component := range reputation.Components {
+		weightedSum += component.Value * component.Weight
+		totalWeight += component.Weight
+	}
+	if totalWeight > 0 {
+		reputation.Score = weightedSum / totalWeight
+	}
+
+	return reputation, nil
+}
+
+// Re-calculates the reputation of the supplier and saves it. Only the
+// reputation field is written, so concurrent updates of the supplier are kept.
+func (record *SupplierRecord) UpdateReputation(cfg *types.ReputationConfig, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	reputation, err := record.CalculateReputation(cfg, mongoDB, l)
+	if err != nil {
+		return err
+	}
+	record.Reputation = reputation
+
+	suppliersCollection := mongoDB.GetCollection(types.SuppliersCollection)
+	supplier_filter := bson.D{{Key: "_id", Value: record.ID}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "reputation", Value: record.Reputation}}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err = suppliersCollection.UpdateOne(ctxM, supplier_filter, update)
+	if err != nil {
+		l.Error().Err(err).Str("address", record.Address).Str("service", record.Service).Msg("Could not update supplier reputation in MongoDB.")
+		return err
+	}
+	return nil
+}
+
+// Loads all the task buffers of a given type of the supplier
+func loadSupplierTasks(record *SupplierRecord, taskType string, mongoDB mongodb.MongoDb, l *zerolog.Logger) ([]TaskInterface, error) {
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		return nil, fmt.Errorf("task type %s not registered", taskType)
+	}
+
+	tasksCollection := mongoDB.GetCollection(registration.Collection)
+	task_filter := bson.D{{Key: "task_data.supplier_id", Value: record.ID}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cursor, err := tasksCollection.Find(ctxM, task_filter)
+	if err != nil {
+		l.Error().Err(err).Str("address", record.Address).Str("service", record.Service).Str("task_type", taskType).Msg("Could not retrieve supplier tasks from MongoDB.")
+		return nil, err
+	}
+	defer cursor.Close(ctxM)
+
+	tasks := make([]TaskInterface, 0)
+	for cursor.Next(ctxM) {
+		task := registration.NewTask()
+		if err := cursor.Decode(task); err != nil {
+			l.Error().Err(err).Str("address", record.Address).Str("service", record.Service).Str("task_type", taskType).Msg("Could not decode supplier task from MongoDB.")
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, cursor.Err()
+}
+
+func clamp01(value float64) float64 {
+	if value < 0 {
+		return 0
+	}
+	if value > 1 {
+		return 1
+	}
+	return value
+}
+
+// Checks the reputation configuration, a nil configuration disables the
+// reputation score. The latency metric must be provided by a task type used by
+// the configured frameworks.
+func ValidateReputationConfig(cfg *types.ReputationConfig, configMap map[string]types.FrameworkConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	totalWeight := cfg.ErrorRateWeight + cfg.LatencyWeight + cfg.AvailabilityWeight
+	for taxonomy, weight := range cfg.TaxonomyWeights {
+		if weight < 0 {
+			return fmt.Errorf("taxonomy_weights %s cannot be negative", taxonomy)
+		}
+		totalWeight += weight
+	}
+	if cfg.ErrorRateWeight < 0 || cfg.LatencyWeight < 0 || cfg.AvailabilityWeight < 0 {
+		return fmt.Errorf("weights cannot be negative")
+	}
+	if totalWeight == 0 {
+		return fmt.Errorf("at least one weight must be larger than zero")
+	}
+	if cfg.LatencyWeight > 0 {
+		if cfg.MaxLatency <= 0 {
+			return fmt.Errorf("max_latency must be larger than zero")
+		}
+		found := false
+		for _, frameworkCfg := range configMap {
+			for _, taskType := range frameworkCfg.TasksTypes {
+				if TaskTypeHasMetric(taskType, cfg.LatencyMetric) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("no task type used by the frameworks has the latency_metric %q", cfg.LatencyMetric)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+	"math"
+)
+
+//------------------------------------------------------------------------------
+// Adaptive sampling allocation
+//------------------------------------------------------------------------------
+
+// Standard error assumed for a score with less than two samples, it is the
+// largest deviation of a score in the [0, 1] range
+const SamplingUnknownStdError float64 = 0.5
+
+// Metrics of the dependency rules that are estimates of the task score, only
+// these are used to measure the nearness to a threshold
+var samplingScoreMetrics = map[string]bool{
+	"mean_score":          true,
+	"median_score":        true,
+	"weighted_mean_score": true,
+	"score_lower_bound":   true,
+	"score_upper_bound":   true,
+}
+
+// A task of the supplier that can receive samples
+type SamplingCandidate struct {
+	Framework string
+	Task      string
+	Record    TaskInterface
+	Blacklist []int
+	// Samples already requested and not processed yet
+	InQueue uint32
+	// Samples requested regardless of the budget (trigger_minimum)
+	MinTrigger uint32
+	// Thresholds of the dependency rules on the task score
+	Thresholds []float64
+
+	// Allocation results, the priority terms are the ones of the first sample
+	Allocated uint32
+	StdError  float64
+	Deficit   float64
+	Nearness  float64
+	Priority  float64
+}
+
+// Creates a sampling candidate, looking for the dependency rules of all
+// frameworks that check the score of the task
+func NewSamplingCandidate(framework string, task string, record TaskInterface, inQueue uint32, minTrigger uint32, blacklist []int, configMap map[string]types.FrameworkConfig) *SamplingCandidate {
+	candidate := SamplingCandidate{
+		Framework:  framework,
+		Task:       task,
+		Record:     record,
+		Blacklist:  blacklist,
+		InQueue:    inQueue,
+		MinTrigger: minTrigger,
+		Thresholds: make([]float64, 0),
+	}
+	for _, frameworkCfg := range configMap {
+		for _, rules := range frameworkCfg.TasksDependency {
+			for _, rule := range rules {
+				candidate.Thresholds = append(candidate.Thresholds, collectScoreThresholds(rule, framework, task)...)
+			}
+		}
+	}
+	return &candidate
+}
+
+// Returns the thresholds of the score rules (and their children) that check
+// the given framework-task pair
+func collectScoreThresholds(rule types.DependencyRule, framework string, task string) []float64 {
+	thresholds := make([]float64, 0)
+	for _, child := range rule.All {
+		thresholds = append(thresholds, collectScoreThresholds(child, framework, task)...)
+	}
+	for _, child := range rule.Any {
+		thresholds = append(thresholds, collectScoreThresholds(child, framework, task)...)
+	}
+	if rule.Framework == framework && rule.Task == task && samplingScoreMetrics[rule.Metric] {
+		thresholds = append(thresholds, rule.Value.Number)
+	}
+	return thresholds
+}
+
+// Number of samples the task will have once the pending and the given extra
+// samples are processed
+func (candidate *SamplingCandidate) expectedSamples(extra uint32) uint32 {
+	return candidate.Record.GetNumOkSamples() + candidate.InQueue + extra
+}
+
+// Number of samples that can still be requested without exceeding the
+// maximum concurrent samples of the task
+func (candidate *SamplingCandidate) capacity() uint32 {
+	maxConcurrent := candidate.Record.GetMaxConcurrentSamplesPerTask()
+	if candidate.InQueue >= maxConcurrent {
+		return 0
+	}
+	return maxConcurrent - candidate.InQueue
+}
+
+// Returns true if the task is below its minimum number of samples after
+// receiving the given extra samples
+func (candidate *SamplingCandidate) isIncomplete(extra uint32) bool {
+	return candidate.expectedSamples(extra) < candidate.Record.GetMinSamplesPerTask()
+}
+
+// Calculates the priority of the next sample of the task, given the extra
+// samples already allocated to it
+func (candidate *SamplingCandidate) priority(cfg *types.SamplingConfig, extra uint32) (priority float64, stdError float64, deficit float64, nearness float64) {
+	samples := float64(candidate.expectedSamples(extra))
+
+	// Standard error of the score, only tasks with a score deviation have it
+	if std, ok := candidate.Record.GetMetric("std_score"); ok {
+		if samples < 2 {
+			stdError = SamplingUnknownStdError
+		} else {
+			stdError = std / math.Sqrt(samples)
+		}
+	}
+
+	// Missing fraction of the minimum samples
+	minSamples := float64(candidate.Record.GetMinSamplesPerTask())
+	if minSamples > 0 && samples < minSamples {
+		deficit = (minSamples - samples) / minSamples
+	}
+
+	// Nearness to the closest threshold, as the normal density of the
+	// distance measured in standard errors
+	if stdError > 0 {
+		if score, ok := candidate.Record.GetMetric("mean_score"); ok {
+			for _, threshold := range candidate.Thresholds {
+				z := (score - threshold) / stdError
+				nearness = math.Max(nearness, math.Exp(-0.5*z*z))
+			}
+		}
+	}
+
+	priority = cfg.StdErrorWeight*stdError + cfg.DeficitWeight*deficit + cfg.ThresholdWeight*nearness
+	return priority, stdError, deficit, nearness
+}
+
+// Splits the supplier budget among the candidates. The trigger_minimum samples
+// are allocated first and count against the budget, then each remaining
+// sample goes to the candidate with the largest priority. Candidates that
+// already have their minimum samples only receive more if their priority is
+// at least the configured minimum. The allocation is written in the
+// candidates.
+func AllocateSamples(candidates []*SamplingCandidate, cfg *types.SamplingConfig) {
+
+	budget := cfg.SupplierBudget
+	for _, candidate := range candidates {
+		candidate.Priority, candidate.StdError, candidate.Deficit, candidate.Nearness = candidate.priority(cfg, 0)
+		// Forced samples
+		forced := candidate.MinTrigger
+		if forced > candidate.capacity() {
+			forced = candidate.capacity()
+		}
+		candidate.Allocated = forced
+		if forced > budget {
+			budget = 0
+		} else {
+			budget -= forced
+		}
+	}
+
+	for ; budget > 0; budget-- {
+		var best *SamplingCandidate
+		bestPriority := 0.0
+		for _, candidate := range candidates {
+			if candidate.Allocated >= candidate.capacity() {
+				continue
+			}
+			priority, _, _, _ := candidate.priority(cfg, candidate.Allocated)
+			if priority <= 0 {
+				continue
+			}
+			if !candidate.isIncomplete(candidate.Allocated) && priority < cfg.MinPriority {
+				continue
+			}
+			if best == nil || priority > bestPriority {
+				best = candidate
+				bestPriority = priority
+			}
+		}
+		if best == nil {
+			// Nothing else is worth sampling
+			break
+		}
+		best.Allocated++
+	}
+}
+
+// Checks the sampling configuration, a nil configuration disables the
+// adaptive allocation.
+func ValidateSamplingConfig(cfg *types.SamplingConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.SupplierBudget == 0 {
+		return fmt.Errorf("supplier_budget must be larger than zero")
+	}
+	if cfg.StdErrorWeight < 0 || cfg.DeficitWeight < 0 || cfg.ThresholdWeight < 0 {
+		return fmt.Errorf("weights cannot be negative")
+	}
+	if cfg.DeficitWeight == 0 {
+		// Tasks without score deviation (i.e. signatures) would never be filled
+		return fmt.Errorf("deficit_weight must be larger than zero")
+	}
+	if cfg.MinPriority < 0 {
+		return fmt.Errorf("min_priority cannot be negative")
+	}
+	return nil
+}
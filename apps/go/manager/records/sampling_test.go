@@ -0,0 +1,142 @@
+package records
+
+import (
+	"manager/types"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A sampling candidate whose buffer holds the given number of OK samples
+type candidateSpec struct {
+	okSamples     uint32
+	minSamples    uint32
+	maxConcurrent uint32
+	meanScore     float32
+	stdScore      float32
+	inQueue       uint32
+	minTrigger    uint32
+	thresholds    []float64
+}
+
+func newTestCandidate(name string, spec candidateSpec) *SamplingCandidate {
+	record := &NumericalTaskRecord{}
+	record.TaskData.BufferConfig = types.TaskBufferConfig{
+		CircularBufferLength:        50,
+		MinSamplesPerTask:           spec.minSamples,
+		MaxConcurrentSamplesPerTask: spec.maxConcurrent,
+	}
+	record.NewTask(primitive.NewObjectID(), "lmeh", name, types.EpochStart, &nopLogger)
+	now := time.Now().UTC()
+	for i := uint32(0); i < spec.okSamples; i++ {
+		record.CircBuffer.Times[i] = now
+	}
+	if spec.okSamples > 0 {
+		record.CircBuffer.Indexes.End = spec.okSamples - 1
+	}
+	record.CircBuffer.NumSamples = spec.okSamples
+	record.MeanScore = spec.meanScore
+	record.StdScore = spec.stdScore
+
+	candidate := NewSamplingCandidate("lmeh", name, record, spec.inQueue, spec.minTrigger, nil, nil)
+	candidate.Thresholds = append(candidate.Thresholds, spec.thresholds...)
+	return candidate
+}
+
+func TestAllocateSamples(t *testing.T) {
+	cfg := types.SamplingConfig{
+		SupplierBudget: 4,
+		StdErrorWeight: 1,
+		DeficitWeight:  1,
+		MinPriority:    0.05,
+	}
+	complete := candidateSpec{okSamples: 10, minSamples: 10, maxConcurrent: 10, meanScore: 0.5, stdScore: 0.3}
+
+	tests := []struct {
+		name       string
+		cfg        types.SamplingConfig
+		candidates []candidateSpec
+		want       []uint32
+	}{
+		{
+			name: "forced samples are capped by the capacity and use the budget",
+			cfg:  cfg,
+			candidates: []candidateSpec{
+				{okSamples: 2, minSamples: 10, maxConcurrent: 3, minTrigger: 5},
+				{okSamples: 2, minSamples: 10, maxConcurrent: 10},
+			},
+			want: []uint32{3, 1},
+		},
+		{
+			name: "forced samples beyond the budget",
+			cfg:  cfg,
+			candidates: []candidateSpec{
+				{okSamples: 2, minSamples: 10, maxConcurrent: 10, minTrigger: 6},
+				{okSamples: 2, minSamples: 10, maxConcurrent: 10},
+			},
+			want: []uint32{6, 0},
+		},
+		{
+			name: "incomplete tasks first",
+			cfg:  cfg,
+			candidates: []candidateSpec{
+				complete,
+				{okSamples: 2, minSamples: 10, maxConcurrent: 10, meanScore: 0.5, stdScore: 0.3},
+			},
+			want: []uint32{0, 4},
+		},
+		{
+			name: "no samples over the concurrent limit",
+			cfg:  cfg,
+			candidates: []candidateSpec{
+				{okSamples: 2, minSamples: 10, maxConcurrent: 3, inQueue: 3},
+				{okSamples: 2, minSamples: 10, maxConcurrent: 3, inQueue: 1},
+			},
+			want: []uint32{0, 2},
+		},
+		{
+			name: "larger standard error first",
+			cfg:  types.SamplingConfig{SupplierBudget: 2, StdErrorWeight: 1, DeficitWeight: 1},
+			candidates: []candidateSpec{
+				{okSamples: 10, minSamples: 10, maxConcurrent: 10, meanScore: 0.5, stdScore: 0.1},
+				{okSamples: 10, minSamples: 10, maxConcurrent: 10, meanScore: 0.5, stdScore: 0.4},
+			},
+			want: []uint32{0, 2},
+		},
+		{
+			name: "complete tasks below the minimum priority",
+			cfg:  types.SamplingConfig{SupplierBudget: 4, StdErrorWeight: 1, DeficitWeight: 1, MinPriority: 0.5},
+			candidates: []candidateSpec{
+				complete,
+				complete,
+			},
+			want: []uint32{0, 0},
+		},
+		{
+			name: "near a threshold first",
+			cfg:  types.SamplingConfig{SupplierBudget: 1, StdErrorWeight: 1, DeficitWeight: 1, ThresholdWeight: 1},
+			candidates: []candidateSpec{
+				{okSamples: 10, minSamples: 10, maxConcurrent: 10, meanScore: 0.5, stdScore: 0.3, thresholds: []float64{0.9}},
+				{okSamples: 10, minSamples: 10, maxConcurrent: 10, meanScore: 0.5, stdScore: 0.3, thresholds: []float64{0.52}},
+			},
+			want: []uint32{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := make([]*SamplingCandidate, len(tt.candidates))
+			for i, spec := range tt.candidates {
+				candidates[i] = newTestCandidate(string(rune('a'+i)), spec)
+			}
+			cfg := tt.cfg
+			AllocateSamples(candidates, &cfg)
+			for i, candidate := range candidates {
+				if candidate.Allocated != tt.want[i] {
+					t.Errorf("candidate %d allocated %d, want %d", i, candidate.Allocated, tt.want[i])
+				}
+			}
+		})
+	}
+}
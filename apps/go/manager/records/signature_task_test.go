@@ -0,0 +1,80 @@
+package records
+
+import (
+	"manager/types"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSignatureProcessDataErrorClasses(t *testing.T) {
+	errorCodes := &types.ErrorCodesConfig{
+		Punishable: []int{RelayResponseCodes.Supplier},
+		Neutral:    []int{RelayResponseCodes.OutOfSession},
+	}
+	ok := func(signature string) SignatureSample {
+		return SignatureSample{Signature: signature}
+	}
+	failed := func(code int) SignatureSample {
+		return SignatureSample{StatusCode: code, ErrorString: "failed"}
+	}
+
+	tests := []struct {
+		name             string
+		samples          []SignatureSample
+		wantSignature    string
+		wantErrorCode    int
+		wantAvailability float32
+	}{
+		{
+			name:             "last answered sample",
+			samples:          []SignatureSample{ok("a"), ok("b")},
+			wantSignature:    "b",
+			wantAvailability: 1,
+		},
+		{
+			name:             "neutral errors keep the signature",
+			samples:          []SignatureSample{ok("a"), failed(RelayResponseCodes.OutOfSession), failed(RelayResponseCodes.OutOfSession)},
+			wantSignature:    "a",
+			wantAvailability: 1.0 / 3.0,
+		},
+		{
+			name:          "only neutral errors",
+			samples:       []SignatureSample{failed(RelayResponseCodes.OutOfSession)},
+			wantSignature: "",
+		},
+		{
+			name:             "punishable error clears the signature",
+			samples:          []SignatureSample{ok("a"), failed(RelayResponseCodes.Supplier), failed(RelayResponseCodes.OutOfSession)},
+			wantSignature:    "",
+			wantErrorCode:    RelayResponseCodes.Supplier,
+			wantAvailability: 2.0 / 3.0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := &SignatureTaskRecord{}
+			record.TaskData.BufferConfig = types.TaskBufferConfig{CircularBufferLength: 10, ErrorCodes: errorCodes}
+			record.NewTask(primitive.NewObjectID(), "signatures", "tokenizer", types.EpochStart, &nopLogger)
+			date := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			for i, sample := range tt.samples {
+				if _, err := record.InsertSample(date.Add(time.Duration(i)*time.Hour), sample, &nopLogger); err != nil {
+					t.Fatal(err)
+				}
+				// Process after each sample, as the results are processed
+				if err := record.ProcessData(&nopLogger); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if record.LastSignature != tt.wantSignature || record.ErrorCode != tt.wantErrorCode {
+				t.Errorf("signature %q (error code %d), want %q (error code %d)",
+					record.LastSignature, record.ErrorCode, tt.wantSignature, tt.wantErrorCode)
+			}
+			if !floatEqual(float64(record.Availability), float64(tt.wantAvailability)) {
+				t.Errorf("availability %v, want %v", record.Availability, tt.wantAvailability)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package records
+
+import (
+	"context"
+	"fmt"
+	"manager/types"
+	"packages/mongodb"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//------------------------------------------------------------------------------
+// Stale tasks
+//------------------------------------------------------------------------------
+
+// Default parameters of the stale task reaper
+const (
+	StaleTasksDefaultMaxAgeHours uint = uint(TaskTTLDays) * 24
+	StaleTasksDefaultBatchSize   uint = 200
+	StaleTasksDefaultMaxBatches  uint = 10
+)
+
+// Error string of the samples of the tasks that never finished
+const StaleTaskErrorString string = "task timed out"
+
+// Returns the tasks that are not done and were created before the given date,
+// oldest first. The creation date is taken from the task ObjectID.
+func FindStaleTasks(olderThan time.Time, limit int64, mongoDB mongodb.MongoDb, l *zerolog.Logger) ([]types.TaskRequestRecord, error) {
+
+	tasksCollection := mongoDB.GetCollection(types.TaskCollection)
+	task_filter := bson.D{
+		{Key: "done", Value: false},
+		{Key: "_id", Value: bson.D{{Key: "$lt", Value: primitive.NewObjectIDFromTimestamp(olderThan)}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(limit)
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	cursor, err := tasksCollection.Find(ctxM, task_filter, opts)
+	if err != nil {
+		l.Error().Err(err).Msg("Could not retrieve stale tasks from MongoDB.")
+		return nil, err
+	}
+	defer cursor.Close(ctxM)
+
+	tasks := make([]types.TaskRequestRecord, 0)
+	if err = cursor.All(ctxM, &tasks); err != nil {
+		l.Error().Err(err).Msg("Could not decode stale tasks from MongoDB.")
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// Inserts the given number of timeout samples (relay errors) in the task
+// buffer, one per unfinished doc. As with any other sample, they are dropped
+// by the buffer if the framework ignores the relay error code.
+func InsertTimeoutSamples(task TaskInterface, qty int64, date time.Time, l *zerolog.Logger) error {
+	var sample interface{}
+	switch task.(type) {
+	case *NumericalTaskRecord, *DistributionTaskRecord:
+		sample = ScoresSample{StatusCode: RelayResponseCodes.Relay, ErrorString: StaleTaskErrorString}
+	case *SignatureTaskRecord:
+		sample = SignatureSample{StatusCode: RelayResponseCodes.Relay, ErrorString: StaleTaskErrorString}
+	default:
+		return fmt.Errorf("timeout samples not supported by task type %T", task)
+	}
+
+	for i := int64(0); i < qty; i++ {
+		if _, err := task.InsertSample(date, sample, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the number of prompts of a task without a response. This must be
+// called before the task tree is deleted.
+func CountTaskTimeouts(taskID primitive.ObjectID, mongoDB mongodb.MongoDb, l *zerolog.Logger) (int64, error) {
+	task_filter := bson.D{{Key: "task_id", Value: taskID}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	prompts, err := mongoDB.GetCollection(types.PromptsCollection).CountDocuments(ctxM, task_filter)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not count prompts in MongoDB.")
+		return 0, err
+	}
+	responses, err := mongoDB.GetCollection(types.ResponsesCollection).CountDocuments(ctxM, task_filter)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not count responses in MongoDB.")
+		return 0, err
+	}
+	if prompts <= responses {
+		return 0, nil
+	}
+	return prompts - responses, nil
+}
+
+// Adds the relays of a task that never finished to the supplier availability:
+// the existing responses as they are, and the given prompts without a response
+// (see CountTaskTimeouts) as relay errors in the hour the task was created.
+// This must be called before the task tree is deleted.
+func RecordSupplierTimeouts(supplierID primitive.ObjectID, taskID primitive.ObjectID, timeouts int64, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+
+	err := RecordSupplierAvailability(supplierID, taskID, mongoDB, l)
+	if err != nil {
+		return err
+	}
+	if timeouts <= 0 {
+		return nil
+	}
+
+	availabilityCollection := mongoDB.GetCollection(types.SupplierAvailabilityCollection)
+	hour := taskID.Timestamp().UTC().Truncate(time.Hour)
+	bucket_filter := bson.D{{Key: "supplier_id", Value: supplierID}, {Key: "hour", Value: hour}}
+	update := bson.D{{Key: "$inc", Value: bson.D{
+		{Key: "total", Value: timeouts},
+		{Key: "down", Value: timeouts},
+		{Key: "codes." + strconv.Itoa(RelayResponseCodes.Relay), Value: timeouts},
+	}}}
+	opts := options.Update().SetUpsert(true)
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err = availabilityCollection.UpdateOne(ctxM, bucket_filter, update, opts)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Time("hour", hour).Msg("Could not update supplier availability in MongoDB.")
+		return err
+	}
+	return nil
+}
+
+// Fields of a stale task that mark the steps of its recording already done, so
+// they are not repeated if a later step fails
+const (
+	StaleTaskBufferRecorded       string = "stale_buffer_recorded"
+	StaleTaskAvailabilityRecorded string = "stale_availability_recorded"
+)
+
+// Marks a step of the recording of a stale task as done
+func MarkStaleTaskStep(taskID primitive.ObjectID, step string, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	tasksCollection := mongoDB.GetCollection(types.TaskCollection)
+	task_filter := bson.D{{Key: "_id", Value: taskID}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: step, Value: true}}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err := tasksCollection.UpdateOne(ctxM, task_filter, update)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Str("step", step).Msg("Could not mark stale task step in MongoDB.")
+		return err
+	}
+	return nil
+}
+
+// Returns the number of instances (docs) of the task that are not done, at
+// most the quantity of the task. A doc can have several prompts (i.e. one per
+// choice of a loglikelihood doc) but it is a single buffer sample.
+func CountUnfinishedInstances(taskReq types.TaskRequestRecord, mongoDB mongodb.MongoDb, l *zerolog.Logger) (int64, error) {
+	instancesCollection := mongoDB.GetCollection(types.InstanceCollection)
+	instance_filter := bson.D{{Key: "task_id", Value: taskReq.Id}, {Key: "done", Value: false}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	unfinished, err := instancesCollection.CountDocuments(ctxM, instance_filter)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskReq.Id.String()).Msg("Could not count instances in MongoDB.")
+		return 0, err
+	}
+	if taskReq.Qty > 0 && unfinished > int64(taskReq.Qty) {
+		unfinished = int64(taskReq.Qty)
+	}
+	return unfinished, nil
+}
+
+// Closes a reaped task without removing it, used when the tasks are kept in
+// the DB for debugging. The task is marked as evaluated and dropped, so it is
+// not picked up by the evaluator nor reaped again.
+func CloseStaleTask(taskID primitive.ObjectID, mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+	tasksCollection := mongoDB.GetCollection(types.TaskCollection)
+	task_filter := bson.D{{Key: "_id", Value: taskID}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "done", Value: true},
+		{Key: "evaluated", Value: true},
+		{Key: "drop", Value: true},
+	}}}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	_, err := tasksCollection.UpdateOne(ctxM, task_filter, update)
+	if err != nil {
+		l.Error().Err(err).Str("TaskID", taskID.String()).Msg("Could not close stale task in MongoDB.")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package records
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// ------------------------------------------------------------------------------
+// Statistics helpers used by the task records
+// ------------------------------------------------------------------------------
+
+// Returns the weight of a sample of a given age, using an exponential decay
+// with the given half-life. A half-life of zero disables the decay and all
+// samples weight the same.
+func decayWeight(sampleTime time.Time, now time.Time, halfLifeDays float64) float64 {
+	if halfLifeDays <= 0 {
+		return 1.0
+	}
+	ageDays := now.Sub(sampleTime).Hours() / 24.0
+	if ageDays < 0 {
+		ageDays = 0
+	}
+	return math.Pow(0.5, ageDays/halfLifeDays)
+}
+
+// Calculates the weighted mean, median and standard deviation of the given
+// values. The standard deviation uses reliability weights, so with all weights
+// equal to one it is the same as the (unbiased) sample standard deviation.
+func weightedMeanMedianStd(values []float64, weights []float64) (mean float64, median float64, std float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+
+	sumW := 0.0
+	sumW2 := 0.0
+	for i, value := range values {
+		mean += weights[i] * value
+		sumW += weights[i]
+		sumW2 += weights[i] * weights[i]
+	}
+	if sumW <= 0 {
+		return 0, 0, 0
+	}
+	mean /= sumW
+
+	// Variance with reliability weights
+	denominator := sumW - sumW2/sumW
+	if denominator > 0 {
+		for i, value := range values {
+			std += weights[i] * (value - mean) * (value - mean)
+		}
+		std = math.Sqrt(std / denominator)
+	}
+
+	// Weighted median, the value where the accumulated weight crosses half of
+	// the total weight (average of both neighbors on an exact tie)
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+	half := sumW / 2.0
+	accumulated := 0.0
+	for pos, idx := range order {
+		accumulated += weights[idx]
+		if accumulated > half {
+			median = values[idx]
+			break
+		}
+		if accumulated == half && pos+1 < len(order) {
+			median = (values[idx] + values[order[pos+1]]) / 2.0
+			break
+		}
+		median = values[idx]
+	}
+
+	return mean, median, std
+}
+
+// Confidence level used for the score intervals
+const ScoreConfidenceLevel float64 = 0.95
+
+// Two sided normal quantile for the ScoreConfidenceLevel
+const scoreConfidenceZ float64 = 1.959963984540054
+
+// Number of resamples used by the bootstrap intervals
+const bootstrapResamples int = 1000
+
+// Names of the methods used to calculate the confidence intervals
+const (
+	ConfidenceMethodNone      = "none"
+	ConfidenceMethodWilson    = "wilson"
+	ConfidenceMethodBootstrap = "bootstrap"
+)
+
+// Kish's effective sample size of a weighted sample. With all weights equal it
+// is the number of samples.
+func effectiveSampleSize(weights []float64) float64 {
+	sumW := 0.0
+	sumW2 := 0.0
+	for _, w := range weights {
+		sumW += w
+		sumW2 += w * w
+	}
+	if sumW2 == 0 {
+		return 0
+	}
+	return sumW * sumW / sumW2
+}
+
+// Returns true if all values are either zero or one
+func isBinarySample(values []float64) bool {
+	for _, value := range values {
+		if value != 0 && value != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Calculates a confidence interval for the (weighted) mean of the values.
+// Binary samples use the Wilson score interval, all others a percentile
+// bootstrap. In both cases the effective sample size is used, so decayed
+// samples count less towards the interval width.
+func meanConfidenceInterval(values []float64, weights []float64) (low float64, high float64, nEff float64, method string) {
+	if len(values) == 0 {
+		return 0, 0, 0, ConfidenceMethodNone
+	}
+	nEff = effectiveSampleSize(weights)
+	mean, _, _ := weightedMeanMedianStd(values, weights)
+
+	if isBinarySample(values) {
+		low, high = wilsonInterval(mean, nEff, scoreConfidenceZ)
+		return low, high, nEff, ConfidenceMethodWilson
+	}
+
+	if nEff < 2 {
+		// Not enough data to resample, the interval collapses
+		return mean, mean, nEff, ConfidenceMethodNone
+	}
+	low, high = bootstrapInterval(values, weights, int(math.Round(nEff)), ScoreConfidenceLevel)
+	return low, high, nEff, ConfidenceMethodBootstrap
+}
+
+// Wilson score interval for a proportion p observed over n trials
+func wilsonInterval(p float64, n float64, z float64) (low float64, high float64) {
+	if n <= 0 {
+		return 0, 1
+	}
+	z2 := z * z
+	denominator := 1 + z2/n
+	center := (p + z2/(2*n)) / denominator
+	margin := (z / denominator) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	low = math.Max(0, center-margin)
+	high = math.Min(1, center+margin)
+	return low, high
+}
+
+// Percentile bootstrap of the mean. Samples are drawn with probability
+// proportional to their weights, each resample has resampleSize elements. The
+// random source has a fixed seed, so the same buffer always produces the same
+// interval.
+func bootstrapInterval(values []float64, weights []float64, resampleSize int, level float64) (low float64, high float64) {
+	// Cumulative weights to draw samples
+	cumulative := make([]float64, len(weights))
+	total := 0.0
+	for i, w := range weights {
+		total += w
+		cumulative[i] = total
+	}
+
+	rng := rand.New(rand.NewPCG(42, 4242))
+	means := make([]float64, bootstrapResamples)
+	for b := 0; b < bootstrapResamples; b++ {
+		sum := 0.0
+		for i := 0; i < resampleSize; i++ {
+			idx := sort.SearchFloat64s(cumulative, rng.Float64()*total)
+			if idx >= len(values) {
+				idx = len(values) - 1
+			}
+			sum += values[idx]
+		}
+		means[b] = sum / float64(resampleSize)
+	}
+	sort.Float64s(means)
+
+	alpha := (1 - level) / 2
+	low = means[int(math.Floor(alpha*float64(bootstrapResamples-1)))]
+	high = means[int(math.Ceil((1-alpha)*float64(bootstrapResamples-1)))]
+	return low, high
+}
+
+// A change of the mean found in a series of values
+type changePoint struct {
+	// Position of the first value after the change
+	Index      int
+	BeforeMean float64
+	BeforeStd  float64
+	AfterMean  float64
+	AfterStd   float64
+	// Welch's t statistic of the difference of the means (before - after)
+	Statistic float64
+}
+
+// Finds the most likely change of the mean in a series of values using the
+// CUSUM of the deviations from the global mean: the change is located where the
+// cumulative sum is furthest from zero. Both segments must have at least
+// minSegment values, otherwise no change is returned.
+func cusumChangePoint(values []float64, minSegment int) (changePoint, bool) {
+	n := len(values)
+	if minSegment < 2 || n < 2*minSegment {
+		return changePoint{}, false
+	}
+
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(n)
+
+	bestIdx := -1
+	bestAbs := 0.0
+	cusum := 0.0
+	for i := 0; i < n-1; i++ {
+		cusum += values[i] - mean
+		// The change would be after this value
+		if i+1 < minSegment || n-(i+1) < minSegment {
+			continue
+		}
+		if math.Abs(cusum) > bestAbs {
+			bestAbs = math.Abs(cusum)
+			bestIdx = i + 1
+		}
+	}
+	if bestIdx < 0 {
+		return changePoint{}, false
+	}
+
+	cp := changePoint{Index: bestIdx}
+	cp.BeforeMean, cp.BeforeStd = stat.MeanStdDev(values[:bestIdx], nil)
+	cp.AfterMean, cp.AfterStd = stat.MeanStdDev(values[bestIdx:], nil)
+	stdErr := math.Sqrt(cp.BeforeStd*cp.BeforeStd/float64(bestIdx) + cp.AfterStd*cp.AfterStd/float64(n-bestIdx))
+	diff := cp.BeforeMean - cp.AfterMean
+	switch {
+	case stdErr > 0:
+		cp.Statistic = diff / stdErr
+	case diff > 0:
+		// Two constant segments
+		cp.Statistic = math.Inf(1)
+	case diff < 0:
+		cp.Statistic = math.Inf(-1)
+	}
+	return cp, true
+}
@@ -0,0 +1,257 @@
+package records
+
+import (
+	"math"
+	"testing"
+)
+
+func floatEqual(a float64, b float64) bool {
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) < 1e-4
+}
+
+func TestWeightedMeanMedianStd(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []float64
+		weights    []float64
+		wantMean   float64
+		wantMedian float64
+		wantStd    float64
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:       "single value",
+			values:     []float64{5},
+			weights:    []float64{1},
+			wantMean:   5,
+			wantMedian: 5,
+		},
+		{
+			name:       "equal weights, odd length",
+			values:     []float64{3, 1, 2},
+			weights:    []float64{1, 1, 1},
+			wantMean:   2,
+			wantMedian: 2,
+			wantStd:    1,
+		},
+		{
+			name:       "equal weights, even length averages the middle values",
+			values:     []float64{4, 1, 3, 2},
+			weights:    []float64{1, 1, 1, 1},
+			wantMean:   2.5,
+			wantMedian: 2.5,
+			wantStd:    math.Sqrt(5.0 / 3.0),
+		},
+		{
+			name:       "weighted",
+			values:     []float64{1, 10},
+			weights:    []float64{3, 1},
+			wantMean:   3.25,
+			wantMedian: 1,
+			wantStd:    math.Sqrt(40.5),
+		},
+		{
+			name:    "zero weights",
+			values:  []float64{1, 2},
+			weights: []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mean, median, std := weightedMeanMedianStd(tt.values, tt.weights)
+			if !floatEqual(mean, tt.wantMean) || !floatEqual(median, tt.wantMedian) || !floatEqual(std, tt.wantStd) {
+				t.Errorf("got mean %v, median %v, std %v, want %v, %v, %v",
+					mean, median, std, tt.wantMean, tt.wantMedian, tt.wantStd)
+			}
+		})
+	}
+}
+
+func TestEffectiveSampleSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights []float64
+		want    float64
+	}{
+		{name: "empty"},
+		{name: "equal weights", weights: []float64{2, 2, 2, 2}, want: 4},
+		{name: "decayed weights", weights: []float64{1, 0.5}, want: 1.8},
+		{name: "zero weights", weights: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveSampleSize(tt.weights); !floatEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeanConfidenceInterval(t *testing.T) {
+	ones := func(n int) []float64 {
+		weights := make([]float64, n)
+		for i := range weights {
+			weights[i] = 1
+		}
+		return weights
+	}
+	half := make([]float64, 100)
+	for i := 0; i < 50; i++ {
+		half[i] = 1
+	}
+
+	tests := []struct {
+		name       string
+		values     []float64
+		weights    []float64
+		wantLow    float64
+		wantHigh   float64
+		wantMethod string
+	}{
+		{
+			name:       "empty",
+			wantMethod: ConfidenceMethodNone,
+		},
+		{
+			name:       "binary samples use the Wilson interval",
+			values:     half,
+			weights:    ones(100),
+			wantLow:    0.40383,
+			wantHigh:   0.59617,
+			wantMethod: ConfidenceMethodWilson,
+		},
+		{
+			name:       "all zeros",
+			values:     make([]float64, 10),
+			weights:    ones(10),
+			wantLow:    0,
+			wantHigh:   0.27753,
+			wantMethod: ConfidenceMethodWilson,
+		},
+		{
+			name:       "single non binary sample collapses",
+			values:     []float64{0.5},
+			weights:    ones(1),
+			wantLow:    0.5,
+			wantHigh:   0.5,
+			wantMethod: ConfidenceMethodNone,
+		},
+		{
+			name:       "constant non binary samples",
+			values:     []float64{0.5, 0.5, 0.5, 0.5},
+			weights:    ones(4),
+			wantLow:    0.5,
+			wantHigh:   0.5,
+			wantMethod: ConfidenceMethodBootstrap,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			low, high, _, method := meanConfidenceInterval(tt.values, tt.weights)
+			if method != tt.wantMethod {
+				t.Errorf("method %s, want %s", method, tt.wantMethod)
+			}
+			if !floatEqual(low, tt.wantLow) || !floatEqual(high, tt.wantHigh) {
+				t.Errorf("interval [%v, %v], want [%v, %v]", low, high, tt.wantLow, tt.wantHigh)
+			}
+		})
+	}
+}
+
+func TestBootstrapIntervalContainsTheMean(t *testing.T) {
+	values := []float64{0.1, 0.4, 0.35, 0.8, 0.6, 0.2, 0.9, 0.55}
+	weights := []float64{1, 1, 1, 1, 1, 1, 1, 1}
+	mean, _, _ := weightedMeanMedianStd(values, weights)
+	low, high, nEff, method := meanConfidenceInterval(values, weights)
+	if method != ConfidenceMethodBootstrap || !floatEqual(nEff, 8) {
+		t.Fatalf("method %s with %v effective samples, want %s with 8", method, nEff, ConfidenceMethodBootstrap)
+	}
+	if low >= mean || high <= mean {
+		t.Errorf("interval [%v, %v] does not contain the mean %v", low, high, mean)
+	}
+	// The random source is seeded, the interval must not change between calls
+	low2, high2, _, _ := meanConfidenceInterval(values, weights)
+	if low != low2 || high != high2 {
+		t.Errorf("interval changed between calls, [%v, %v] and [%v, %v]", low, high, low2, high2)
+	}
+}
+
+func TestCusumChangePoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		values     []float64
+		minSegment int
+		wantFound  bool
+		want       changePoint
+	}{
+		{
+			name:       "too short for two segments",
+			values:     []float64{0, 0, 1},
+			minSegment: 2,
+		},
+		{
+			name:       "segment too small",
+			values:     []float64{0, 0, 1, 1},
+			minSegment: 1,
+		},
+		{
+			name:       "constant series",
+			values:     []float64{1, 1, 1, 1, 1, 1},
+			minSegment: 2,
+		},
+		{
+			name:       "constant segments",
+			values:     []float64{0, 0, 0, 0, 1, 1, 1, 1},
+			minSegment: 2,
+			wantFound:  true,
+			want:       changePoint{Index: 4, BeforeMean: 0, AfterMean: 1, Statistic: math.Inf(-1)},
+		},
+		{
+			name:       "noisy drop",
+			values:     []float64{1, 1.2, 0.8, 1, 0, 0.2, -0.2, 0},
+			minSegment: 2,
+			wantFound:  true,
+			want: changePoint{
+				Index:      4,
+				BeforeMean: 1,
+				BeforeStd:  math.Sqrt(0.08 / 3),
+				AfterMean:  0,
+				AfterStd:   math.Sqrt(0.08 / 3),
+				Statistic:  1 / math.Sqrt(0.08/3/2),
+			},
+		},
+		{
+			name:       "change limited by the minimum segment",
+			values:     []float64{5, 0, 0, 0, 0, 0},
+			minSegment: 3,
+			wantFound:  true,
+			want:       changePoint{Index: 3, BeforeMean: 5.0 / 3, BeforeStd: math.Sqrt(25.0 / 3), AfterMean: 0, Statistic: (5.0 / 3) / math.Sqrt(25.0/9)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := cusumChangePoint(tt.values, tt.minSegment)
+			if found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if !found {
+				return
+			}
+			if got.Index != tt.want.Index ||
+				!floatEqual(got.BeforeMean, tt.want.BeforeMean) || !floatEqual(got.BeforeStd, tt.want.BeforeStd) ||
+				!floatEqual(got.AfterMean, tt.want.AfterMean) || !floatEqual(got.AfterStd, tt.want.AfterStd) ||
+				!floatEqual(got.Statistic, tt.want.Statistic) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -31,6 +31,13 @@ type SupplierRecord struct {
 	// This is the last time the Manager updated the supplier's entries: Updated buffers, dropped old samples, etc.
 	LastProcessHeight int64     `bson:"last_process_height"`
 	LastProcessTime   time.Time `bson:"last_process_time"`
+	// Percentage of the relays answered by the supplier in the last 24 hours
+	// and 7 days, calculated from the supplier availability buckets
+	Uptime24h        float64   `bson:"uptime_24h"`
+	Uptime7d         float64   `bson:"uptime_7d"`
+	UptimeUpdateTime time.Time `bson:"uptime_update_time"`
+	// Composite score of the supplier, see ReputationConfig
+	Reputation SupplierReputation `bson:"reputation"`
 }
 
 func (record *SupplierRecord) FindAndLoadSupplier(supplier types.SupplierData, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
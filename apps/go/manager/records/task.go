@@ -7,7 +7,6 @@ import (
 	"packages/mongodb"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -37,6 +36,20 @@ type BaseTaskRecord struct {
 	// supplier
 	LastOk       time.Time `bson:"last_ok"`
 	LastOkHeight int64     `bson:"last_ok_height"`
+
+	// Counters of the evaluations that failed (non-zero result status), by the
+	// policy applied to them
+	EvaluatorFailures          uint64 `bson:"evaluator_failures"`
+	SupplierEvaluationFailures uint64 `bson:"supplier_evaluation_failures"`
+	EvaluationRetries          uint64 `bson:"evaluation_retries"`
+	IgnoredEvaluationFailures  uint64 `bson:"ignored_evaluation_failures"`
+
+	// Incremented on each write of the record, used to detect writes that
+	// happened after the record was loaded (see SupplierTaskBuffers.Save)
+	Version uint64 `bson:"version"`
+
+	// Buffer sizing resolved from the framework config, not stored
+	BufferConfig types.TaskBufferConfig `bson:"-"`
 }
 
 func (record *BaseTaskRecord) GetSupplierID() primitive.ObjectID {
@@ -87,7 +100,32 @@ func (record *BaseTaskRecord) UpdateLastOkHeight(height int64) (err error) {
 	return nil
 }
 
-// The maximum age of a task entry.
+func (record *BaseTaskRecord) GetVersion() uint64 {
+	return record.Version
+}
+
+func (record *BaseTaskRecord) BumpVersion() {
+	record.Version++
+}
+
+func (record *BaseTaskRecord) AddEvaluationFailure(policy string) (err error) {
+	switch policy {
+	case EvaluationPolicyRetry:
+		record.EvaluationRetries++
+	case EvaluationPolicySupplier:
+		record.SupplierEvaluationFailures++
+	case EvaluationPolicyEvaluator:
+		record.EvaluatorFailures++
+	case EvaluationPolicyIgnore:
+		record.IgnoredEvaluationFailures++
+	default:
+		return fmt.Errorf("unknown evaluation policy %s", policy)
+	}
+	return nil
+}
+
+// The maximum age of a task entry, older tasks that are not done are removed
+// by the StaleTaskReaper workflow.
 // NOTE : This value should be high enough so that any workflow schedule is
 // executed at least twice. While unlikely to set a workflow with days between
 // calls, this might happen.
@@ -104,13 +142,14 @@ type TaskInterface interface {
 	InsertSample(timeSample time.Time, data interface{}, l *zerolog.Logger) (ok bool, err error)
 	GetNumSamples() uint32
 	GetNumOkSamples() uint32
+	GetSupplierID() primitive.ObjectID
 	GetFramework() string
 	GetTask() string
 	GetMinSamplesPerTask() uint32
 	GetMaxConcurrentSamplesPerTask() uint32
 	GetCircularBufferLength() uint32
 	GetSampleTTLDays() uint32
-	GetResultStruct() ResultInterface
+	SetBufferConfig(bufferCfg types.TaskBufferConfig, l *zerolog.Logger) (resized bool, err error)
 	GetLastSeen() time.Time
 	GetLastHeight() int64
 	GetLastOk() time.Time
@@ -119,8 +158,12 @@ type TaskInterface interface {
 	UpdateLastHeight(height int64) (err error)
 	UpdateLastOk(timeSample time.Time) (err error)
 	UpdateLastOkHeight(height int64) (err error)
+	AddEvaluationFailure(policy string) (err error)
+	GetVersion() uint64
+	BumpVersion()
 	IsOK() bool
 	IsEqual(interface{}) (statusOK bool, err error)
+	GetMetric(name string) (value float64, ok bool)
 	NewTask(supplierID primitive.ObjectID, framework string, task string, date time.Time, l *zerolog.Logger)
 	LoadTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error)
 	UpdateTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error)
@@ -160,64 +203,171 @@ func GetTaxonomyData(
 
 }
 
+// Get all the taxonomy summaries of a supplier, by taxonomy name
+func LoadTaxonomySummaries(
+	supplierID primitive.ObjectID,
+	mongoDB mongodb.MongoDb,
+	l *zerolog.Logger) (map[string]types.TaxonomySummary, error) {
+
+	task_filter := bson.D{{Key: "supplier_id", Value: supplierID}}
+	taxonomyCollection := mongoDB.GetCollection(types.TaxonomySummariesCollection)
+
+	// Set mongo context
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	cursor, err := taxonomyCollection.Find(ctxM, task_filter)
+	if err != nil {
+		l.Error().Err(err).Str("supplier_id", supplierID.String()).Msg("Could not retrieve taxonomy summaries from MongoDB.")
+		return nil, err
+	}
+	defer cursor.Close(ctxM)
+
+	summaries := make(map[string]types.TaxonomySummary)
+	for cursor.Next(ctxM) {
+		var summary types.TaxonomySummary
+		if err := cursor.Decode(&summary); err != nil {
+			l.Error().Err(err).Str("supplier_id", supplierID.String()).Msg("Could not decode taxonomy summary from MongoDB.")
+			return nil, err
+		}
+		summaries[summary.TaxonomyName] = summary
+	}
+	if err := cursor.Err(); err != nil {
+		l.Error().Err(err).Str("supplier_id", supplierID.String()).Msg("Could not retrieve taxonomy summaries from MongoDB.")
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// Returned by UpdateTask when the record was written by another process after
+// it was loaded, the caller must load it again and redo its changes
+var ErrTaskVersionConflict = fmt.Errorf("task record changed since it was loaded")
+
+// Writes a task record, only if its version in the database is the one it was
+// loaded with (new records are inserted). Returns true if the record was
+// already in the database.
+func updateTaskRecord(
+	taskType string,
+	record TaskInterface,
+	supplierID primitive.ObjectID,
+	framework string,
+	task string,
+	mongoDB mongodb.MongoDb,
+	l *zerolog.Logger) (bool, error) {
+
+	tasksCollection := mongoDB.GetCollection(getTaskTypeCollection(taskType))
+
+	opts := options.Update().SetUpsert(true)
+	task_filter := bson.D{
+		{Key: "task_data.supplier_id", Value: supplierID},
+		{Key: "task_data.framework", Value: framework},
+		{Key: "task_data.task", Value: task},
+		taskVersionFilter(record.GetVersion()),
+	}
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	record.BumpVersion()
+	update := bson.D{{Key: "$set", Value: record}}
+	result, err := tasksCollection.UpdateOne(ctxM, task_filter, update, opts)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// The version did not match, so the upsert tried to insert the
+			// record again
+			return false, ErrTaskVersionConflict
+		}
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// Filter on the version of a loaded buffer. Buffers written before the version
+// was stored have no version field, they match the version zero.
+func taskVersionFilter(version uint64) bson.E {
+	if version == 0 {
+		return bson.E{Key: "task_data.version", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}}
+	}
+	return bson.E{Key: "task_data.version", Value: version}
+}
+
 // Get specific task data from a supplier record
 func GetTaskData(
 	supplierID primitive.ObjectID,
 	taskType string,
 	framework string,
 	task string,
+	configMap map[string]types.FrameworkConfig,
 	create_new bool,
 	mongoDB mongodb.MongoDb,
 	l *zerolog.Logger) (TaskInterface, bool) {
 
+	// Get the task type constructor
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		l.Error().
+			Str("supplierID", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Str("task_type", taskType).
+			Msg("task type not registered")
+		return nil, false
+	}
+
+	// Get the buffer sizing of this framework-task
+	bufferCfg, err := GetTaskBufferConfig(framework, task, taskType, configMap)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("invalid buffer configuration")
+		return nil, false
+	}
+
 	// Look for entry
-	if taskType == NumericalTaskTypeName {
-		// get task record
-		var record NumericalTaskRecord
-		found, err := record.LoadTask(supplierID, framework, task, mongoDB, l)
-		if err != nil {
-			l.Error().
-				Str("supplierID", supplierID.String()).
-				Str("framework", framework).
-				Str("task", task).
-				Msg("cannot find default task buffer")
-			return nil, false
-		}
-		if !found {
-			if create_new {
-				// Initialize and save
-				record.NewTask(supplierID, framework, task, types.EpochStart.UTC(), l)
-				record.UpdateTask(supplierID, framework, task, mongoDB, l)
-			} else {
+	record := registration.NewTask()
+	found, err := record.LoadTask(supplierID, framework, task, mongoDB, l)
+	if err != nil {
+		l.Error().
+			Str("supplierID", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("cannot find default task buffer")
+		return nil, false
+	}
+	// Set the buffer config, this will resize existing buffers if the
+	// configured length changed
+	_, err = record.SetBufferConfig(bufferCfg, l)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("supplierID", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("cannot resize task buffer")
+		return nil, false
+	}
+	if !found {
+		if create_new {
+			// Initialize and save
+			record.NewTask(supplierID, framework, task, types.EpochStart.UTC(), l)
+			_, err = record.UpdateTask(supplierID, framework, task, mongoDB, l)
+			if err != nil {
+				l.Error().
+					Err(err).
+					Str("supplierID", supplierID.String()).
+					Str("framework", framework).
+					Str("task", task).
+					Msg("cannot create task buffer")
 				return nil, false
 			}
-		}
-		return &record, true
-	} else if taskType == SignatureTaskTypeName {
-		// set task record
-		var record SignatureTaskRecord
-		found, err := record.LoadTask(supplierID, framework, task, mongoDB, l)
-		if err != nil {
-			l.Error().
-				Str("supplierID", supplierID.String()).
-				Str("framework", framework).
-				Str("task", task).
-				Msg("cannot find default task buffer")
+		} else {
 			return nil, false
 		}
-		if !found {
-			if create_new {
-				// Initialize and save
-				record.NewTask(supplierID, framework, task, types.EpochStart.UTC(), l)
-				record.UpdateTask(supplierID, framework, task, mongoDB, l)
-			} else {
-				return nil, false
-			}
-		}
-		return &record, true
 	}
-
-	return nil, false
+	return record, true
 }
 
 // Depending on the framework-task pair, the type of data that is saved will vary.
@@ -249,12 +399,15 @@ func GetTaskType(framework string, task string, configMap map[string]types.Frame
 
 // Analyzes the taxonomy dependencies and returns if it is possible to proceed with this task triggering/analysis
 // A task can depend on some taxonomies to be passed at a certain level, here we check for that
+// The rules are resolved against the loaded task buffers and taxonomy summaries of the supplier
 func CheckTaxonomyDependency(
 	supplierData *SupplierRecord,
 	framework string,
 	task string,
 	configMap map[string]types.FrameworkConfig,
-	mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
+	taskBuffers *SupplierTaskBuffers,
+	taxonomySummaries map[string]types.TaxonomySummary,
+	l *zerolog.Logger) (bool, error) {
 
 	// Get Framework config
 	frameworkCfg, ok := configMap[framework]
@@ -274,80 +427,16 @@ func CheckTaxonomyDependency(
 			err := fmt.Errorf("cannot find default (or specific) value for task type")
 			return false, err
 		}
-		if len(taskDep) == 0 {
-			l.Error().Str("framework", framework).Str("task", task).Msg("malformed dependency array for task type")
-			err := fmt.Errorf("malformed dependency array for task type")
-			return false, err
-		}
-	}
-
-	// Check dependency
-	depOK := true
-	for idxDep := 0; idxDep < len(taskDep); idxDep++ {
-		// get data from entry
-		frameworkTaxonomyAndStatus := strings.Split(taskDep[idxDep], ":")
-		if len(frameworkTaxonomyAndStatus) != 4 {
-			l.Error().Str("framework", framework).Str("task", task).Msg("malformed taxonomy dependency configuration, expected four elements separated by \":\" ")
-			depOK = false
-			break
-		}
-		if frameworkTaxonomyAndStatus[0] == "none" {
-			// No dependencies
-			l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("No taxonomy dependency: Dependency OK")
-			continue
-		}
-		// All other three must be numerical entries
-		scoreMin, err := strconv.ParseFloat(frameworkTaxonomyAndStatus[1], 64)
-		if err != nil {
-			l.Error().Str("framework", framework).Str("task", task).Str("taxonomy", frameworkTaxonomyAndStatus[0]).Msg("malformed taxonomy dependency configuration, cannot convert string to float for first element.")
-			depOK = false
-			break
-		}
-		// TODO : Implement success rate tracking
-		// successRateMin, err := strconv.ParseFloat(frameworkTaxonomyAndStatus[2], 64)
-		// if err != nil {
-		// 	l.Error().Str("framework", framework).Str("task", task).Str("taxonomy", frameworkTaxonomyAndStatus[0]).Msg("malformed taxonomy dependency configuration, cannot convert string to float for second element.")
-		// 	depOK = false
-		// 	break
-		// }
-		samplesMin, err := strconv.ParseFloat(frameworkTaxonomyAndStatus[3], 64)
-		if err != nil {
-			l.Error().Str("framework", framework).Str("task", task).Str("taxonomy", frameworkTaxonomyAndStatus[0]).Msg("malformed taxonomy dependency configuration, cannot convert string to float for third element.")
-			depOK = false
-			break
-		}
-
-		// Get the taxonomy to evaluate
-		thisTaxonomySummary, found := GetTaxonomyData(supplierData.ID, frameworkTaxonomyAndStatus[0], mongoDB, l)
-		if !found {
-			// The task is not even created, we must fail
-			depOK = false
-			break
-		} else {
-			// Check the condition over the root_c node
-			taxonomyRootNode, found := thisTaxonomySummary.TaxonomyNodesScores["root_c"]
-			if !found {
-				l.Error().Str("framework", framework).Str("task", task).Str("taxonomy", frameworkTaxonomyAndStatus[0]).Msg("malformed taxonomy summary, no root_c node!")
-				depOK = false
-				break
-			}
-
-			if (taxonomyRootNode.Score < scoreMin) ||
-				// (taxonomyRootNode.ErrorRate > (1-successRateMin)) ||
-				(float64(taxonomyRootNode.SampleMin) < samplesMin) {
-				// Condition not met
-				depOK = false
-				break
-			}
-		}
 	}
 
-	return depOK, nil
+	// Check dependency, all entries must be met
+	return evaluateDependencyRule(types.DependencyRule{All: taskDep}, supplierData, framework, task, configMap, taskBuffers, taxonomySummaries, l)
 }
 
 // Analyzes the configuration and returns if it is possible to proceed with this task triggering/analysis
 // A task can depend on others (such as having a tokenizer signature), here we check for that
-func CheckTaskDependency(supplierData *SupplierRecord, framework string, task string, configMap map[string]types.FrameworkConfig, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
+// The rules are resolved against the loaded task buffers and taxonomy summaries of the supplier
+func CheckTaskDependency(supplierData *SupplierRecord, framework string, task string, configMap map[string]types.FrameworkConfig, taskBuffers *SupplierTaskBuffers, taxonomySummaries map[string]types.TaxonomySummary, l *zerolog.Logger) (bool, error) {
 
 	// Get Framework config
 	frameworkCfg, ok := configMap[framework]
@@ -367,79 +456,10 @@ func CheckTaskDependency(supplierData *SupplierRecord, framework string, task st
 			err := fmt.Errorf("cannot find default (or specific) value for task type")
 			return false, err
 		}
-		if len(taskDep) == 0 {
-			l.Error().Str("framework", framework).Str("task", task).Msg("malformed dependency array for task type")
-			err := fmt.Errorf("malformed dependency array for task type")
-			return false, err
-		}
-	}
-
-	// Check dependency
-	depOK := true
-	for idxDep := 0; idxDep < len(taskDep); idxDep++ {
-		// get data from entry
-		frameworkTaskandStatus := strings.Split(taskDep[idxDep], ":")
-		if len(frameworkTaskandStatus) != 4 {
-			l.Error().Str("framework", framework).Str("task", task).Msg("malformed dependency configuration, expected four elements separated by \":\" ")
-			depOK = false
-			break
-		}
-		if frameworkTaskandStatus[0] == "none" {
-			// No dependencies
-			l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("No dependency: Dependency OK")
-			continue
-		}
-		taskType, err := GetTaskType(frameworkTaskandStatus[0], frameworkTaskandStatus[1], configMap, l)
-		if err != nil {
-			l.Error().Str("framework", framework).Str("task", task).Str("task type", taskType).Msg("Error getting task type")
-			return false, err
-		}
-		thisTaskRecord, found := GetTaskData(supplierData.ID, taskType, frameworkTaskandStatus[0], frameworkTaskandStatus[1], false, mongoDB, l)
-		if !found {
-			// The task is not even created, we must fail
-			depOK = false
-			break
-		} else {
-			// Check the condition
-			if frameworkTaskandStatus[2] == "present" {
-				// Task is present, so OK
-				l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("Present: Dependency OK")
-				continue
-			} else if frameworkTaskandStatus[2] == "ok" {
-				// Check for it having a correct value
-				if thisTaskRecord.IsOK() {
-					l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("OK: Dependency OK")
-					continue
-				} else {
-					l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("OK: Dependency NOT OK")
-					depOK = false
-					break
-				}
-			} else if frameworkTaskandStatus[2] == "equal" {
-				ok, err := thisTaskRecord.IsEqual(frameworkTaskandStatus[3])
-				if err != nil {
-					l.Error().Err(err).Str("framework", framework).Str("task", task).Msg("Equality check failed")
-					depOK = false
-					break
-				}
-				if ok {
-					l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("EQUAL: Dependency OK")
-					continue
-				} else {
-					l.Debug().Str("address", supplierData.Address).Str("service", supplierData.Service).Str("framework", framework).Str("task", task).Msg("EQUAL: Dependency NOT OK")
-					depOK = false
-					break
-				}
-
-			} else {
-				l.Error().Str("framework", framework).Str("task", task).Msg("dependency configuration cannot be processed (status type unknown)")
-				depOK = false
-				break
-			}
-		}
 	}
 
-	return depOK, nil
+	// Check dependency, all entries must be met
+	return evaluateDependencyRule(types.DependencyRule{All: taskDep}, supplierData, framework, task, configMap, taskBuffers, taxonomySummaries, l)
 }
 
 // Analyzes the configuration and checks whether the triggering the task will
@@ -470,68 +490,62 @@ func CheckTaskSchedule(taskData TaskInterface, block types.BlockData, configMap
 	}
 
 	// Check schedule
-	frameworkTaskandSchedule := strings.Split(taskSchedule, ":")
-	if len(frameworkTaskandSchedule) != 2 {
-		l.Error().Str("framework", framework).Str("task", task).Msg("malformed dependency configuration, expected two elements separated by \":\" ")
-		return false, nil
-	}
-	if frameworkTaskandSchedule[0] == "none" {
+	if taskSchedule.IsNone() {
 		// No dependencies
 		l.Debug().Str("framework", framework).Str("task", task).Msg("No schedule: Schedule OK")
 		return true, nil
 	}
-	value, err := strconv.ParseInt(frameworkTaskandSchedule[0], 10, 32)
+
+	lastHeight := taskData.GetLastHeight()
+	lastSeen := taskData.GetLastSeen()
+	now := time.Now().UTC()
+
+	// Check time window
+	if !taskSchedule.InWindow(now) {
+		l.Debug().Str("framework", framework).Str("task", task).Msg("Outside schedule window")
+		return false, nil
+	}
+
+	// Check cron schedule
+	cronDue, err := taskSchedule.IsCronDue(lastSeen, now)
 	if err != nil {
-		l.Error().Str("framework", framework).Str("task", task).Msg("malformed dependency configuration, first element must be an integer number")
+		l.Error().Err(err).Str("framework", framework).Str("task", task).Str("cron", taskSchedule.Cron).Msg("cannot parse schedule cron expression")
+		return false, err
+	}
+	if !cronDue {
+		l.Debug().Str("framework", framework).Str("task", task).Msg("Cron schedule not due")
 		return false, nil
 	}
 
-	lastHeight := taskData.GetLastHeight()
-	lastSeen := taskData.GetLastSeen()
-	switch frameworkTaskandSchedule[1] {
-	case "session":
+	// Check minimum interval
+	if !taskSchedule.HasInterval() {
+		return true, nil
+	}
+	switch taskSchedule.Unit {
+	case types.ScheduleUnitSession:
 		// Check if session is within minimum schedule
-		if (block.Height - lastHeight) >= (value * block.BlocksPerSession) {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return (block.Height - lastHeight) >= (taskSchedule.Every * block.BlocksPerSession), nil
 
-	case "block":
+	case types.ScheduleUnitBlock:
 		// Check if amount of blocks have passed
-		if (block.Height - lastHeight) >= value {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return (block.Height - lastHeight) >= taskSchedule.Every, nil
 
-	case "hours":
+	case types.ScheduleUnitHours:
 		// Check if the amount of hours have passed
-		durationThreshold := time.Duration(value) * time.Hour
-
-		if time.Since(lastSeen) >= durationThreshold {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return now.Sub(lastSeen) >= time.Duration(taskSchedule.Every)*time.Hour, nil
 
-	case "minutes":
+	case types.ScheduleUnitMinutes:
 		// Check if the amount of minutes have passed
-		durationThreshold := time.Duration(value) * time.Minute
-
-		if time.Since(lastSeen) >= durationThreshold {
-			return true, nil
-		} else {
-			return false, nil
-		}
+		return now.Sub(lastSeen) >= time.Duration(taskSchedule.Every)*time.Minute, nil
 
 	default:
+		// Cannot happen with a validated configuration
 		l.Error().
 			Str("framework", framework).
 			Str("task", task).
-			Str("second_element", frameworkTaskandSchedule[1]).
-			Msg("schedule configuration cannot be processed (second element type unknown)")
-		return false, nil
+			Str("unit", taskSchedule.Unit).
+			Msg("schedule configuration cannot be processed (unit unknown)")
+		return false, fmt.Errorf("unknown schedule unit %s", taskSchedule.Unit)
 	}
 
 }
@@ -580,9 +594,26 @@ func CheckTaskTriggerMin(taskData TaskInterface, block types.BlockData, configMa
 
 const NumericalTaskTypeName string = "numerical"
 
-// The maximum age of a sample living in a buffer.
-// TODO: Maybe this should be configurable depending on the source, as external
-// sources are sampled slower than Pokt samples.
+func init() {
+	MustRegisterTaskType(TaskTypeRegistration{
+		Name:       NumericalTaskTypeName,
+		Collection: types.NumericalTaskCollection,
+		NewTask:    func() TaskInterface { return &NumericalTaskRecord{} },
+		NewResult:  func() ResultInterface { return &NumericalResultRecord{} },
+		BufferDefaults: types.TaskBufferConfig{
+			CircularBufferLength:        NumericalCircularBufferLength,
+			MinSamplesPerTask:           NumericalMinSamplesPerTask,
+			MaxConcurrentSamplesPerTask: NumericalMaxConcurrentSamplesPerTask,
+			SampleTTLDays:               NumericalSampleTTLDays,
+		},
+		Metrics: NumericalMetrics,
+	})
+}
+
+// The default maximum age of a sample living in a buffer.
+// It can be changed per framework-task using the `sample_ttl_days` field of the
+// `buffer_config` entry, for example external sources are sampled slower than
+// Pokt samples and might need a longer TTL.
 // NOTE : This parameter controls how fast the NumericalSample buffer is renewed, meaning that it controls the sample
 // frequency of all numerical tests. So, if you wish to control the sampling frequency of an specific task you should do
 // the following:*2
@@ -608,12 +639,12 @@ const NumericalTaskTypeName string = "numerical"
 // workflow frequency to renew all samples in the buffer using the `trigger_minimum` minimum setting.
 const NumericalSampleTTLDays uint32 = 32
 
-// Minimum number of samples to have in a task to consider that it does not require more samples
+// Default minimum number of samples to have in a task to consider that it does not require more samples
 // According to "tinyBenchmarks: evaluating LLMs with fewer examples" 100 is enough, but also 50 seems adequate.
 // However, we put this a little higher to have some buffer for the result rollover process that can take some time
 const NumericalMinSamplesPerTask uint32 = 75
 
-// Maximum size of result buffer and also maximum number of samples to ask per task
+// Default maximum size of result buffer and also maximum number of samples to ask per task
 const NumericalMaxConcurrentSamplesPerTask uint32 = 2
 
 // NOTE: Setting this value high means that more tasks are asked at the same time by the Manager to the Sampler at the
@@ -626,7 +657,7 @@ const NumericalMaxConcurrentSamplesPerTask uint32 = 2
 // tasks are reseted and re-sent, creating some pile-up on session changes. So, to be safe and keep the supplier as free
 // as possible, this number needs to be small. Yes, averages will be filled more slowly, but is the price we pay...
 
-// This is the length of the buffer and will set the maximum accuracy of the metric.
+// This is the default length of the buffer and will set the maximum accuracy of the metric.
 const NumericalCircularBufferLength uint32 = 100
 
 // All information for a given task
@@ -641,15 +672,46 @@ type NumericalTaskRecord struct {
 	MeanProcessTime   float32 `bson:"mean_times"`
 	MedianProcessTime float32 `bson:"median_times"`
 	StdProcessTime    float32 `bson:"std_times"`
+	// Time-decayed metrics, each sample weighted by its age. If the decay is
+	// not configured these are equal to the unweighted ones.
+	WeightedMeanScore         float32 `bson:"w_mean_scores"`
+	WeightedMedianScore       float32 `bson:"w_median_scores"`
+	WeightedStdScore          float32 `bson:"w_std_scores"`
+	WeightedMeanProcessTime   float32 `bson:"w_mean_times"`
+	WeightedMedianProcessTime float32 `bson:"w_median_times"`
+	WeightedStdProcessTime    float32 `bson:"w_std_times"`
+	DecayHalfLifeDays         float64 `bson:"decay_half_life_days"`
+	// Confidence interval of the (weighted) mean score and the effective number
+	// of samples used to calculate it
+	ScoreCILow       float32 `bson:"ci_low_scores"`
+	ScoreCIHigh      float32 `bson:"ci_high_scores"`
+	ScoreCIMethod    string  `bson:"ci_method"`
+	EffectiveSamples float32 `bson:"effective_samples"`
 	// Errors
 	ErrorRate  float32     `bson:"error_rate"`
 	ErrorCodes map[int]int `bson:"error_codes"`
+	// Fraction of the buffer samples that got a response, neutral errors (see
+	// the `error_codes` config) only count here
+	Availability      float32     `bson:"availability"`
+	NeutralErrorCodes map[int]int `bson:"neutral_error_codes"`
+	// Date of the first sample after the last score regression detected, only
+	// the samples since then are tested for new regressions
+	LastRegressionDate time.Time `bson:"last_regression_date"`
 	// buffer
 	ScoresSamples []ScoresSample `bson:"scores"`
 	// circular buffer control
 	CircBuffer types.CircularBuffer `bson:"circ_buffer_control"`
+
+	// Events produced by ProcessData, not saved with the record
+	events []SupplierEvent
 }
 
+// Minimum number of correct samples before and after a score regression
+const RegressionMinSegmentSamples int = 10
+
+// Minimum t statistic of the score drop to report it as a regression
+const RegressionMinStatistic float64 = 4.0
+
 type ScoresSample struct {
 	Score       float64 `bson:"score"`
 	ID          int     `bson:"id"`
@@ -659,8 +721,7 @@ type ScoresSample struct {
 }
 
 func (record *NumericalTaskRecord) NewTask(supplierID primitive.ObjectID, framework string, task string, date time.Time, l *zerolog.Logger) {
-	// TODO: Get default values from framework-task
-	bufferLen := NumericalCircularBufferLength
+	bufferLen := record.GetCircularBufferLength()
 	timeArray := make([]time.Time, bufferLen)
 	for i := range timeArray {
 		timeArray[i] = date
@@ -680,8 +741,21 @@ func (record *NumericalTaskRecord) NewTask(supplierID primitive.ObjectID, framew
 	record.MeanProcessTime = 0.0
 	record.MedianProcessTime = 0.0
 	record.StdProcessTime = 0.0
+	record.WeightedMeanScore = 0.0
+	record.WeightedMedianScore = 0.0
+	record.WeightedStdScore = 0.0
+	record.WeightedMeanProcessTime = 0.0
+	record.WeightedMedianProcessTime = 0.0
+	record.WeightedStdProcessTime = 0.0
+	record.DecayHalfLifeDays = 0.0
+	record.ScoreCILow = 0.0
+	record.ScoreCIHigh = 0.0
+	record.ScoreCIMethod = ConfidenceMethodNone
+	record.EffectiveSamples = 0.0
 	record.ErrorRate = 0.0
 	record.ErrorCodes = make(map[int]int, 0)
+	record.Availability = 0.0
+	record.NeutralErrorCodes = make(map[int]int, 0)
 	record.ScoresSamples = make([]ScoresSample, bufferLen)
 
 	record.CircBuffer = types.CircularBuffer{
@@ -699,7 +773,7 @@ func (record *NumericalTaskRecord) NewTask(supplierID primitive.ObjectID, framew
 func (record *NumericalTaskRecord) LoadTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
 
 	task_filter := bson.D{{Key: "task_data.supplier_id", Value: supplierID}, {Key: "task_data.framework", Value: framework}, {Key: "task_data.task", Value: task}}
-	tasksCollection := mongoDB.GetCollection(types.NumericalTaskCollection)
+	tasksCollection := mongoDB.GetCollection(getTaskTypeCollection(NumericalTaskTypeName))
 	opts := options.FindOne()
 
 	// Set mongo context
@@ -731,53 +805,91 @@ func (record *NumericalTaskRecord) UpdateTask(
 	mongoDB mongodb.MongoDb,
 	l *zerolog.Logger) (bool, error) {
 
-	tasksCollection := mongoDB.GetCollection(types.NumericalTaskCollection)
-
-	opts := options.FindOneAndUpdate().SetUpsert(true)
-	task_filter := bson.D{
-		{Key: "task_data.supplier_id", Value: supplierID},
-		{Key: "task_data.framework", Value: framework},
-		{Key: "task_data.task", Value: task},
-	}
-	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
-
-	// Update given struct
-	update := bson.D{{Key: "$set", Value: record}}
-	// Get collection and update
-	var found bool = true
-	err := tasksCollection.FindOneAndUpdate(ctxM, task_filter, update, opts).Decode(record)
+	found, err := updateTaskRecord(NumericalTaskTypeName, record, supplierID, framework, task, mongoDB, l)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			l.Warn().
-				Str("supplier_id", supplierID.String()).
-				Str("framework", framework).
-				Str("task", task).
-				Msg("Numerical Task not found, creating one.")
-			found = false
-		} else {
-			l.Error().Msg("Could not retrieve numerical task data from MongoDB.")
-			return false, err
-		}
+		l.Error().
+			Err(err).
+			Str("supplier_id", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("Could not update numerical task data in MongoDB.")
+		return false, err
+	}
+	if !found {
+		l.Warn().
+			Str("supplier_id", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("Numerical Task not found, creating one.")
 	}
 
 	return found, nil
 }
 
 func (record *NumericalTaskRecord) GetMinSamplesPerTask() uint32 {
-	return NumericalMinSamplesPerTask
+	if record.TaskData.BufferConfig.MinSamplesPerTask == 0 {
+		return NumericalMinSamplesPerTask
+	}
+	return record.TaskData.BufferConfig.MinSamplesPerTask
 }
 
 func (record *NumericalTaskRecord) GetMaxConcurrentSamplesPerTask() uint32 {
-	return NumericalMaxConcurrentSamplesPerTask
+	if record.TaskData.BufferConfig.MaxConcurrentSamplesPerTask == 0 {
+		return NumericalMaxConcurrentSamplesPerTask
+	}
+	return record.TaskData.BufferConfig.MaxConcurrentSamplesPerTask
 }
 
 func (record *NumericalTaskRecord) GetSampleTTLDays() uint32 {
-	return NumericalSampleTTLDays
+	if record.TaskData.BufferConfig.SampleTTLDays == 0 {
+		return NumericalSampleTTLDays
+	}
+	return record.TaskData.BufferConfig.SampleTTLDays
 }
 
 func (record *NumericalTaskRecord) GetCircularBufferLength() uint32 {
-	return NumericalCircularBufferLength
+	if record.TaskData.BufferConfig.CircularBufferLength == 0 {
+		return NumericalCircularBufferLength
+	}
+	return record.TaskData.BufferConfig.CircularBufferLength
+}
+
+// Sets the buffer sizing of the task. If the record already holds a buffer of a
+// different length it is resized, keeping the newest samples.
+func (record *NumericalTaskRecord) SetBufferConfig(bufferCfg types.TaskBufferConfig, l *zerolog.Logger) (resized bool, err error) {
+	record.TaskData.BufferConfig = bufferCfg
+
+	newLen := record.GetCircularBufferLength()
+	if record.CircBuffer.CircBufferLen == 0 || record.CircBuffer.CircBufferLen == newLen {
+		// Nothing to resize (new record or same length)
+		return false, nil
+	}
+
+	l.Info().
+		Str("supplier_id", record.TaskData.SupplierID.String()).
+		Str("framework", record.TaskData.Framework).
+		Str("task", record.TaskData.Task).
+		Uint32("old_length", record.CircBuffer.CircBufferLen).
+		Uint32("new_length", newLen).
+		Msg("Resizing task buffer.")
+
+	keptIdx, err := record.CircBuffer.Resize(newLen, l)
+	if err != nil {
+		return false, err
+	}
+	samples := make([]ScoresSample, newLen)
+	for i, idx := range keptIdx {
+		if int(idx) < len(record.ScoresSamples) {
+			samples[i] = record.ScoresSamples[idx]
+		}
+	}
+	record.ScoresSamples = samples
+
+	return true, nil
+}
+
+func (record *NumericalTaskRecord) GetSupplierID() primitive.ObjectID {
+	return record.TaskData.GetSupplierID()
 }
 
 func (record *NumericalTaskRecord) GetFramework() string {
@@ -824,6 +936,18 @@ func (record *NumericalTaskRecord) UpdateLastOkHeight(height int64) (err error)
 	return nil
 }
 
+func (record *NumericalTaskRecord) AddEvaluationFailure(policy string) (err error) {
+	return record.TaskData.AddEvaluationFailure(policy)
+}
+
+func (record *NumericalTaskRecord) GetVersion() uint64 {
+	return record.TaskData.GetVersion()
+}
+
+func (record *NumericalTaskRecord) BumpVersion() {
+	record.TaskData.BumpVersion()
+}
+
 // Returns the number of valid samples in the circular buffer
 func (record *NumericalTaskRecord) GetNumSamples() uint32 {
 	return record.CircBuffer.NumSamples
@@ -861,6 +985,59 @@ func (record *NumericalTaskRecord) IsEqual(data interface{}) (statusOK bool, err
 	return false, nil
 }
 
+// Metrics that can be used in the dependency rules of numerical tasks
+var NumericalMetrics = []string{
+	"mean_score",
+	"median_score",
+	"std_score",
+	"weighted_mean_score",
+	"score_lower_bound",
+	"score_upper_bound",
+	"effective_samples",
+	"mean_time",
+	"median_time",
+	"weighted_mean_time",
+	"error_rate",
+	"availability",
+	"num_samples",
+	"num_ok_samples",
+}
+
+// Returns the value of a named metric of the task
+func (record *NumericalTaskRecord) GetMetric(name string) (value float64, ok bool) {
+	switch name {
+	case "mean_score":
+		return float64(record.MeanScore), true
+	case "median_score":
+		return float64(record.MedianScore), true
+	case "std_score":
+		return float64(record.StdScore), true
+	case "weighted_mean_score":
+		return float64(record.WeightedMeanScore), true
+	case "score_lower_bound":
+		return float64(record.ScoreCILow), true
+	case "score_upper_bound":
+		return float64(record.ScoreCIHigh), true
+	case "effective_samples":
+		return float64(record.EffectiveSamples), true
+	case "mean_time":
+		return float64(record.MeanProcessTime), true
+	case "median_time":
+		return float64(record.MedianProcessTime), true
+	case "weighted_mean_time":
+		return float64(record.WeightedMeanProcessTime), true
+	case "error_rate":
+		return float64(record.ErrorRate), true
+	case "availability":
+		return float64(record.Availability), true
+	case "num_samples":
+		return float64(record.GetNumSamples()), true
+	case "num_ok_samples":
+		return float64(record.GetNumOkSamples()), true
+	}
+	return 0, false
+}
+
 // Calculate task statistics
 func (record *NumericalTaskRecord) ProcessData(l *zerolog.Logger) (err error) {
 
@@ -873,30 +1050,66 @@ func (record *NumericalTaskRecord) ProcessData(l *zerolog.Logger) (err error) {
 	// Slice the buffer and cast
 	var auxDataScores []float64
 	var auxDataTimes []float64
+	var auxWeights []float64
+	var auxDates []time.Time
+	halfLifeDays := record.TaskData.BufferConfig.GetDecayHalfLifeDays()
+	now := time.Now()
 	totalPunibleErrors := 0
 	punibleErrorsCodes := make(map[int]int)
+	totalNeutralErrors := 0
+	neutralErrorsCodes := make(map[int]int)
 	for _, sampleId := range validIdx {
 		sampleStatus := record.ScoresSamples[sampleId].StatusCode
-		if sampleStatus == 0 {
+		switch record.TaskData.GetErrorCodeClass(sampleStatus) {
+		case ErrorCodeClassOk:
 			// Add sample to data array
 			auxDataScores = append(auxDataScores, float64(record.ScoresSamples[sampleId].Score))
 			auxDataTimes = append(auxDataTimes, float64(record.ScoresSamples[sampleId].RunTime))
-		} else if sampleStatus == RelayResponseCodes.Supplier || sampleStatus == RelayResponseCodes.Evaluation {
-			// This is a Supplier or Evaluation (response) error, we should punish the supplier
+			auxWeights = append(auxWeights, decayWeight(record.CircBuffer.Times[sampleId], now, halfLifeDays))
+			auxDates = append(auxDates, record.CircBuffer.Times[sampleId])
+		case ErrorCodeClassPunishable:
+			// This is an error imputable to the supplier, we should punish it
 			totalPunibleErrors += 1
 			punibleErrorsCodes[sampleStatus] += 1
+		case ErrorCodeClassNeutral:
+			// The supplier did not respond, this only affects its availability
+			totalNeutralErrors += 1
+			neutralErrorsCodes[sampleStatus] += 1
 		}
 	}
 
 	// Total valid samples
 	length := len(auxDataScores)
 
+	// Calculate the time-decayed metrics (before sorting the data arrays)
+	mean, median, std := weightedMeanMedianStd(auxDataScores, auxWeights)
+	record.WeightedMeanScore = float32(mean)
+	record.WeightedMedianScore = float32(median)
+	record.WeightedStdScore = float32(std)
+	mean, median, std = weightedMeanMedianStd(auxDataTimes, auxWeights)
+	record.WeightedMeanProcessTime = float32(mean)
+	record.WeightedMedianProcessTime = float32(median)
+	record.WeightedStdProcessTime = float32(std)
+	record.DecayHalfLifeDays = halfLifeDays
+
+	// Calculate how much we can trust the mean score
+	ciLow, ciHigh, nEff, ciMethod := meanConfidenceInterval(auxDataScores, auxWeights)
+	record.ScoreCILow = float32(ciLow)
+	record.ScoreCIHigh = float32(ciHigh)
+	record.ScoreCIMethod = ciMethod
+	record.EffectiveSamples = float32(nEff)
+
+	// Look for a drop of the score (before sorting the data arrays)
+	record.detectRegression(auxDataScores, auxDates, l)
+
 	// Set errors
 	record.ErrorCodes = punibleErrorsCodes
 	record.ErrorRate = 0.0
 	if float32(length+totalPunibleErrors) > 0 {
 		record.ErrorRate = float32(totalPunibleErrors) / float32(length+totalPunibleErrors)
 	}
+	record.NeutralErrorCodes = neutralErrorsCodes
+	record.Availability = calculateAvailability(length, totalPunibleErrors, totalNeutralErrors)
 
 	// Calculate the scores and times
 	if length == 0 {
@@ -940,6 +1153,56 @@ func (record *NumericalTaskRecord) ProcessData(l *zerolog.Logger) (err error) {
 	return err
 }
 
+// Runs a change-point test over the correct scores, in time order, emitting a
+// score regression event if the score dropped. Only the samples since the last
+// regression are tested, so a regression is reported once.
+func (record *NumericalTaskRecord) detectRegression(scores []float64, dates []time.Time, l *zerolog.Logger) {
+	start := 0
+	for start < len(dates) && dates[start].Before(record.LastRegressionDate) {
+		start++
+	}
+	cp, found := cusumChangePoint(scores[start:], RegressionMinSegmentSamples)
+	if !found || cp.Statistic < RegressionMinStatistic {
+		return
+	}
+
+	changeDate := dates[start+cp.Index]
+	record.LastRegressionDate = changeDate
+	l.Info().
+		Str("supplier_id", record.TaskData.SupplierID.String()).
+		Str("framework", record.TaskData.Framework).
+		Str("task", record.TaskData.Task).
+		Float64("before_mean", cp.BeforeMean).
+		Float64("after_mean", cp.AfterMean).
+		Float64("statistic", cp.Statistic).
+		Msg("Score regression detected.")
+	record.events = append(record.events, SupplierEvent{
+		SupplierID: record.TaskData.SupplierID,
+		Framework:  record.TaskData.Framework,
+		Task:       record.TaskData.Task,
+		EventType:  SupplierEventScoreRegression,
+		Date:       time.Now().UTC(),
+		Height:     record.TaskData.LastHeight,
+		Details: map[string]interface{}{
+			"change_date":    changeDate,
+			"before_mean":    cp.BeforeMean,
+			"before_std":     cp.BeforeStd,
+			"before_samples": cp.Index,
+			"after_mean":     cp.AfterMean,
+			"after_std":      cp.AfterStd,
+			"after_samples":  len(scores) - start - cp.Index,
+			"statistic":      cp.Statistic,
+		},
+	})
+}
+
+// Returns the events produced since the last call and clears them
+func (record *NumericalTaskRecord) PopEvents() []SupplierEvent {
+	events := record.events
+	record.events = nil
+	return events
+}
+
 // Gets the sample index given a step direction (positive: 1 or negative: -1) and for a given marker (start or end of buffer)
 func (record *NumericalTaskRecord) StepIndex(step uint32, marker string, positive_step bool, l *zerolog.Logger) error {
 	return record.CircBuffer.StepIndex(step, marker, positive_step, l)
@@ -947,7 +1210,7 @@ func (record *NumericalTaskRecord) StepIndex(step uint32, marker string, positiv
 
 // Updates the indexes making them point to the initial and final samples in a given time window.
 func (record *NumericalTaskRecord) CycleIndexes(l *zerolog.Logger) (bool, error) {
-	return record.CircBuffer.CycleIndexes(NumericalSampleTTLDays, l)
+	return record.CircBuffer.CycleIndexes(record.GetSampleTTLDays(), l)
 }
 func (record *NumericalTaskRecord) InsertSample(timeSample time.Time, data interface{}, l *zerolog.Logger) (statusOK bool, err error) {
 	// Assert data type
@@ -956,12 +1219,10 @@ func (record *NumericalTaskRecord) InsertSample(timeSample time.Time, data inter
 		return ok, fmt.Errorf("invalid sample data type")
 	}
 
-	// Save sample if it is OK or it is an error imputable to the supplier
-	// the rest are ignored on purpose to avoid polluting the buffer with information
+	// Save sample if it is OK or it is a punishable or neutral error, the rest
+	// are ignored on purpose to avoid polluting the buffer with information
 	// that is not important to the servicer supplier. To debug other errors, check the logs...
-	if dataOk.StatusCode == RelayResponseCodes.Ok ||
-		dataOk.StatusCode == RelayResponseCodes.Supplier ||
-		dataOk.StatusCode == RelayResponseCodes.Evaluation {
+	if record.TaskData.GetErrorCodeClass(dataOk.StatusCode) != ErrorCodeClassIgnored {
 
 		// Increment the end (only on valid data)
 		err = record.StepIndex(1, "end", true, l)
@@ -982,41 +1243,96 @@ func (record *NumericalTaskRecord) InsertSample(timeSample time.Time, data inter
 	return statusOK, nil
 }
 
-func (record *NumericalTaskRecord) GetResultStruct() ResultInterface {
-	var thisTaskResults NumericalResultRecord
-	return &thisTaskResults
-}
-
 // ------------------------------------------------------------------------------
 // SignatureTaskRecord
 // ------------------------------------------------------------------------------
 
 const SignatureTaskTypeName string = "signature"
 
-// The maximum age of a sample living in a buffer.
+func init() {
+	MustRegisterTaskType(TaskTypeRegistration{
+		Name:       SignatureTaskTypeName,
+		Collection: types.SignaturesTaskCollection,
+		NewTask:    func() TaskInterface { return &SignatureTaskRecord{} },
+		NewResult:  func() ResultInterface { return &SignatureResultRecord{} },
+		BufferDefaults: types.TaskBufferConfig{
+			CircularBufferLength:        SignatureCircularBufferLength,
+			MinSamplesPerTask:           SignatureMinSamplesPerTask,
+			MaxConcurrentSamplesPerTask: SignatureMaxConcurrentSamplesPerTask,
+			SampleTTLDays:               SignatureSampleTTLDays,
+			ConsensusMode:               SignatureConsensusLast,
+		},
+		Metrics:       SignatureMetrics,
+		SupportsEqual: true,
+	})
+}
+
+// The default maximum age of a sample living in a buffer.
 // NOTE : See the comments con NumericalSampleTTLDays for more insight on the implications of this variable
 const SignatureSampleTTLDays uint32 = 32
 
-// Minimum number of samples to have in a task to consider that it does not require more samples
+// Default minimum number of samples to have in a task to consider that it does not require more samples
 const SignatureMinSamplesPerTask uint32 = 10
 
-// Maximum size of result buffer and also maximum number of samples to ask per task
+// Default maximum size of result buffer and also maximum number of samples to ask per task
 const SignatureMaxConcurrentSamplesPerTask uint32 = 1
 
-// This is the length of the buffer and will set the maximum accuracy of the metric.
+// This is the default length of the buffer and will set the maximum accuracy of the metric.
 const SignatureCircularBufferLength uint32 = 2 * SignatureMinSamplesPerTask
 
+// Ways of selecting the signature of a task from its buffer
+const (
+	// The signature of the last sample, a single bad response replaces it
+	SignatureConsensusLast string = "last"
+	// The most frequent signature in the buffer, ties go to the newest one
+	SignatureConsensusMajority string = "majority"
+)
+
+// Maximum number of distinct signatures kept in the history of a task, the ones
+// seen longest ago are dropped first
+const SignatureMaxHistoryEntries int = 64
+
 // Signatures task data
 type SignatureTaskRecord struct {
 	TaskData BaseTaskRecord `bson:"task_data"`
 	// Specific fields
+	// The current signature of the supplier, selected using the consensus mode
 	LastSignature string `bson:"last_signature"`
+	// Consensus mode used to select the signature and the fraction of the
+	// buffer samples that agree with it
+	ConsensusMode string  `bson:"consensus_mode"`
+	Agreement     float64 `bson:"agreement"`
 	// Errors
 	ErrorCode int `bson:"error_code"`
+	// Fraction of the buffer samples that got a response
+	Availability float32 `bson:"availability"`
 	// buffers
 	Signatures []SignatureSample `bson:"signatures"`
 	// circular buffer control
 	CircBuffer types.CircularBuffer `bson:"circ_buffer_control"`
+	// All distinct signatures seen (not only the ones in the buffer)
+	History []SignatureHistoryEntry `bson:"history"`
+
+	// Correct samples inserted since the last call to ProcessData, used to
+	// update the history
+	newSamples []signatureInsertion
+	// Events produced by ProcessData, not saved with the record
+	events []SupplierEvent
+}
+
+// A distinct signature seen for a supplier and task
+type SignatureHistoryEntry struct {
+	Signature   string    `bson:"signature"`
+	FirstSeen   time.Time `bson:"first_seen"`
+	LastSeen    time.Time `bson:"last_seen"`
+	FirstHeight int64     `bson:"first_height"`
+	LastHeight  int64     `bson:"last_height"`
+	Count       uint64    `bson:"count"`
+}
+
+type signatureInsertion struct {
+	signature string
+	date      time.Time
 }
 
 type SignatureSample struct {
@@ -1027,8 +1343,7 @@ type SignatureSample struct {
 }
 
 func (record *SignatureTaskRecord) NewTask(supplierID primitive.ObjectID, framework string, task string, date time.Time, l *zerolog.Logger) {
-	// TODO: Get default values from framework-task
-	bufferLen := SignatureCircularBufferLength
+	bufferLen := record.GetCircularBufferLength()
 	timeArray := make([]time.Time, bufferLen)
 	for i := range timeArray {
 		timeArray[i] = date
@@ -1043,7 +1358,11 @@ func (record *SignatureTaskRecord) NewTask(supplierID primitive.ObjectID, framew
 	record.TaskData.LastSeen = time.Now().UTC().Add(-24 * time.Hour)
 
 	record.LastSignature = ""
+	record.ConsensusMode = record.GetConsensusMode()
+	record.Agreement = 0.0
 	record.ErrorCode = 0
+	record.Availability = 0.0
+	record.History = make([]SignatureHistoryEntry, 0)
 	record.Signatures = make([]SignatureSample, bufferLen)
 	record.CircBuffer = types.CircularBuffer{
 		CircBufferLen: bufferLen,
@@ -1059,7 +1378,7 @@ func (record *SignatureTaskRecord) NewTask(supplierID primitive.ObjectID, framew
 func (record *SignatureTaskRecord) LoadTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
 
 	task_filter := bson.D{{Key: "task_data.supplier_id", Value: supplierID}, {Key: "task_data.framework", Value: framework}, {Key: "task_data.task", Value: task}}
-	tasksCollection := mongoDB.GetCollection(types.SignaturesTaskCollection)
+	tasksCollection := mongoDB.GetCollection(getTaskTypeCollection(SignatureTaskTypeName))
 	opts := options.FindOne()
 
 	// Set mongo context
@@ -1086,45 +1405,93 @@ func (record *SignatureTaskRecord) LoadTask(supplierID primitive.ObjectID, frame
 
 func (record *SignatureTaskRecord) UpdateTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
 
-	tasksCollection := mongoDB.GetCollection(types.SignaturesTaskCollection)
-
-	opts := options.FindOneAndUpdate().SetUpsert(true)
-	task_filter := bson.D{{Key: "task_data.supplier_id", Value: supplierID}, {Key: "task_data.framework", Value: framework}, {Key: "task_data.task", Value: task}}
-	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
-
-	// Update given struct
-	update := bson.D{{Key: "$set", Value: record}}
-	// Get collection and update
-	var found bool = true
-	err := tasksCollection.FindOneAndUpdate(ctxM, task_filter, update, opts).Decode(record)
+	found, err := updateTaskRecord(SignatureTaskTypeName, record, supplierID, framework, task, mongoDB, l)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			l.Warn().Str("supplier_id", supplierID.String()).Str("framework", framework).Str("task", task).Msg("Signature Task not found, creating one.")
-			found = false
-		} else {
-			l.Error().Str("supplier_id", supplierID.String()).Str("framework", framework).Str("task", task).Msg("Could not retrieve signature task data from MongoDB.")
-			return false, err
-		}
+		l.Error().Err(err).Str("supplier_id", supplierID.String()).Str("framework", framework).Str("task", task).Msg("Could not update signature task data in MongoDB.")
+		return false, err
+	}
+	if !found {
+		l.Warn().Str("supplier_id", supplierID.String()).Str("framework", framework).Str("task", task).Msg("Signature Task not found, creating one.")
 	}
 
 	return found, nil
 }
 
 func (record *SignatureTaskRecord) GetMinSamplesPerTask() uint32 {
-	return SignatureMinSamplesPerTask
+	if record.TaskData.BufferConfig.MinSamplesPerTask == 0 {
+		return SignatureMinSamplesPerTask
+	}
+	return record.TaskData.BufferConfig.MinSamplesPerTask
 }
 
 func (record *SignatureTaskRecord) GetMaxConcurrentSamplesPerTask() uint32 {
-	return SignatureMaxConcurrentSamplesPerTask
+	if record.TaskData.BufferConfig.MaxConcurrentSamplesPerTask == 0 {
+		return SignatureMaxConcurrentSamplesPerTask
+	}
+	return record.TaskData.BufferConfig.MaxConcurrentSamplesPerTask
 }
 
 func (record *SignatureTaskRecord) GetSampleTTLDays() uint32 {
-	return SignatureSampleTTLDays
+	if record.TaskData.BufferConfig.SampleTTLDays == 0 {
+		return SignatureSampleTTLDays
+	}
+	return record.TaskData.BufferConfig.SampleTTLDays
+}
+
+func (record *SignatureTaskRecord) GetConsensusMode() string {
+	if record.TaskData.BufferConfig.ConsensusMode == "" {
+		return SignatureConsensusLast
+	}
+	return record.TaskData.BufferConfig.ConsensusMode
+}
+
+func (record *SignatureTaskRecord) GetMinAgreement() float64 {
+	return record.TaskData.BufferConfig.GetMinAgreement()
 }
 
 func (record *SignatureTaskRecord) GetCircularBufferLength() uint32 {
-	return SignatureCircularBufferLength
+	if record.TaskData.BufferConfig.CircularBufferLength == 0 {
+		return SignatureCircularBufferLength
+	}
+	return record.TaskData.BufferConfig.CircularBufferLength
+}
+
+// Sets the buffer sizing of the task. If the record already holds a buffer of a
+// different length it is resized, keeping the newest samples.
+func (record *SignatureTaskRecord) SetBufferConfig(bufferCfg types.TaskBufferConfig, l *zerolog.Logger) (resized bool, err error) {
+	record.TaskData.BufferConfig = bufferCfg
+
+	newLen := record.GetCircularBufferLength()
+	if record.CircBuffer.CircBufferLen == 0 || record.CircBuffer.CircBufferLen == newLen {
+		// Nothing to resize (new record or same length)
+		return false, nil
+	}
+
+	l.Info().
+		Str("supplier_id", record.TaskData.SupplierID.String()).
+		Str("framework", record.TaskData.Framework).
+		Str("task", record.TaskData.Task).
+		Uint32("old_length", record.CircBuffer.CircBufferLen).
+		Uint32("new_length", newLen).
+		Msg("Resizing task buffer.")
+
+	keptIdx, err := record.CircBuffer.Resize(newLen, l)
+	if err != nil {
+		return false, err
+	}
+	samples := make([]SignatureSample, newLen)
+	for i, idx := range keptIdx {
+		if int(idx) < len(record.Signatures) {
+			samples[i] = record.Signatures[idx]
+		}
+	}
+	record.Signatures = samples
+
+	return true, nil
+}
+
+func (record *SignatureTaskRecord) GetSupplierID() primitive.ObjectID {
+	return record.TaskData.GetSupplierID()
 }
 
 func (record *SignatureTaskRecord) GetFramework() string {
@@ -1171,6 +1538,18 @@ func (record *SignatureTaskRecord) UpdateLastOkHeight(height int64) (err error)
 	return nil
 }
 
+func (record *SignatureTaskRecord) AddEvaluationFailure(policy string) (err error) {
+	return record.TaskData.AddEvaluationFailure(policy)
+}
+
+func (record *SignatureTaskRecord) GetVersion() uint64 {
+	return record.TaskData.GetVersion()
+}
+
+func (record *SignatureTaskRecord) BumpVersion() {
+	record.TaskData.BumpVersion()
+}
+
 // Gets the sample index given a step direction (positive: 1 or negative: -1) and for a given marker (start or end of buffer)
 func (record *SignatureTaskRecord) StepIndex(step uint32, marker string, positive_step bool, l *zerolog.Logger) error {
 	return record.CircBuffer.StepIndex(step, marker, positive_step, l)
@@ -1178,7 +1557,7 @@ func (record *SignatureTaskRecord) StepIndex(step uint32, marker string, positiv
 
 // Updates the indexes making them point to the initial and final samples in a given time window.
 func (record *SignatureTaskRecord) CycleIndexes(l *zerolog.Logger) (bool, error) {
-	return record.CircBuffer.CycleIndexes(SignatureSampleTTLDays, l)
+	return record.CircBuffer.CycleIndexes(record.GetSampleTTLDays(), l)
 }
 
 // Returns the number of valid samples in the circular buffer
@@ -1214,10 +1593,8 @@ func (record *SignatureTaskRecord) InsertSample(timeSample time.Time, data inter
 
 	// Increment the end
 	err = record.StepIndex(1, "end", true, l)
-	// Save sample if it is OK or it is an error imputable to the supplier
-	if dataOk.StatusCode == RelayResponseCodes.Ok ||
-		dataOk.StatusCode == RelayResponseCodes.Supplier ||
-		dataOk.StatusCode == RelayResponseCodes.Evaluation {
+	// Save sample if it is OK or it is a punishable or neutral error
+	if record.TaskData.GetErrorCodeClass(dataOk.StatusCode) != ErrorCodeClassIgnored {
 
 		record.Signatures[record.CircBuffer.Indexes.End].Signature = dataOk.Signature
 		record.Signatures[record.CircBuffer.Indexes.End].ID = dataOk.ID
@@ -1228,6 +1605,7 @@ func (record *SignatureTaskRecord) InsertSample(timeSample time.Time, data inter
 	if dataOk.StatusCode == RelayResponseCodes.Ok {
 		// Sample was ok
 		statusOK = true
+		record.newSamples = append(record.newSamples, signatureInsertion{signature: dataOk.Signature, date: timeSample})
 	}
 
 	return statusOK, nil
@@ -1235,7 +1613,7 @@ func (record *SignatureTaskRecord) InsertSample(timeSample time.Time, data inter
 
 // Returns True if the task is ok, meaning that their values are updated and correct
 func (record *SignatureTaskRecord) IsOK() bool {
-	if record.LastSignature != "" && record.ErrorCode == 0 {
+	if record.LastSignature != "" && record.ErrorCode == 0 && record.isAgreementOK() {
 		// there is a signature available, so it is OK
 		return true
 	} else {
@@ -1243,6 +1621,40 @@ func (record *SignatureTaskRecord) IsOK() bool {
 	}
 }
 
+// Returns True if enough samples agree with the signature. A zero minimum
+// disables the check (records processed before the agreement was tracked have
+// no agreement value).
+func (record *SignatureTaskRecord) isAgreementOK() bool {
+	minAgreement := record.GetMinAgreement()
+	if minAgreement <= 0 {
+		return true
+	}
+	return record.Agreement > minAgreement
+}
+
+// Metrics that can be used in the dependency rules of signature tasks
+var SignatureMetrics = []string{
+	"agreement",
+	"availability",
+	"num_samples",
+	"num_ok_samples",
+}
+
+// Returns the value of a named metric of the task
+func (record *SignatureTaskRecord) GetMetric(name string) (value float64, ok bool) {
+	switch name {
+	case "agreement":
+		return record.Agreement, true
+	case "availability":
+		return float64(record.Availability), true
+	case "num_samples":
+		return float64(record.GetNumSamples()), true
+	case "num_ok_samples":
+		return float64(record.GetNumOkSamples()), true
+	}
+	return 0, false
+}
+
 // Returns True if the task average matches a value
 func (record *SignatureTaskRecord) IsEqual(data interface{}) (statusOK bool, err error) {
 	// Assert data type
@@ -1251,7 +1663,7 @@ func (record *SignatureTaskRecord) IsEqual(data interface{}) (statusOK bool, err
 		return ok, fmt.Errorf("invalid data type for equality")
 	}
 	// Check match
-	if record.LastSignature == matchStr {
+	if record.LastSignature == matchStr && record.isAgreementOK() {
 		return true, nil
 	} else {
 		return false, nil
@@ -1260,20 +1672,154 @@ func (record *SignatureTaskRecord) IsEqual(data interface{}) (statusOK bool, err
 
 // Process the buffer data to produce the signature metrics
 func (record *SignatureTaskRecord) ProcessData(l *zerolog.Logger) (err error) {
-	// Just update the last signature
-	lastSampleStatus := record.Signatures[record.CircBuffer.Indexes.End].StatusCode
-	if lastSampleStatus == 0 {
-		record.LastSignature = record.Signatures[record.CircBuffer.Indexes.End].Signature
-		record.ErrorCode = 0
-	} else {
-		record.LastSignature = ""
-		record.ErrorCode = lastSampleStatus
+	// Count the correct signatures in the buffer, from oldest to newest
+	validIdx, err := record.CircBuffer.GetBufferValidIndexes(l)
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]int)
+	majoritySignature := ""
+	// Neutral errors are not taken into account for the signature, only for
+	// the availability
+	answered := 0
+	punishable := 0
+	neutral := 0
+	var lastAnsweredIdx uint32
+	for _, sampleId := range validIdx {
+		sample := record.Signatures[sampleId]
+		switch record.TaskData.GetErrorCodeClass(sample.StatusCode) {
+		case ErrorCodeClassNeutral:
+			neutral++
+			continue
+		case ErrorCodeClassIgnored:
+			continue
+		case ErrorCodeClassPunishable:
+			punishable++
+		}
+		answered++
+		lastAnsweredIdx = sampleId
+		if sample.StatusCode != 0 || sample.Signature == "" {
+			continue
+		}
+		counts[sample.Signature]++
+		// Newer samples win ties
+		if counts[sample.Signature] >= counts[majoritySignature] {
+			majoritySignature = sample.Signature
+		}
+	}
+
+	// Update the signature, only if there is an answered sample. Neutral and
+	// ignored errors are not the supplier's fault, so they must not change a
+	// known signature.
+	record.ConsensusMode = record.GetConsensusMode()
+	if answered > 0 {
+		lastSampleStatus := record.Signatures[lastAnsweredIdx].StatusCode
+		if record.ConsensusMode == SignatureConsensusMajority && majoritySignature != "" {
+			// A single failed or different sample does not change the signature
+			record.LastSignature = majoritySignature
+			record.ErrorCode = 0
+		} else if lastSampleStatus == 0 {
+			record.LastSignature = record.Signatures[lastAnsweredIdx].Signature
+			record.ErrorCode = 0
+		} else {
+			record.LastSignature = ""
+			record.ErrorCode = lastSampleStatus
+		}
+
+		// The agreement is measured over all the answered samples in the
+		// buffer, failed samples count as disagreeing
+		record.Agreement = 0.0
+		if record.LastSignature != "" {
+			record.Agreement = float64(counts[record.LastSignature]) / float64(answered)
+		}
+	}
+	record.Availability = calculateAvailability(answered-punishable, punishable, neutral)
+
+	// Track the new signatures in the history, the height is not known when
+	// inserting, but it is set (for all the new samples) before processing
+	for _, sample := range record.newSamples {
+		record.updateHistory(sample.signature, sample.date, record.TaskData.LastHeight, l)
 	}
+	record.newSamples = nil
 
 	return nil
 }
 
-func (record *SignatureTaskRecord) GetResultStruct() ResultInterface {
-	var thisTaskResults SignatureResultRecord
-	return &thisTaskResults
+// Updates the history with a correct signature, emitting a change event if the
+// signature was never seen before
+func (record *SignatureTaskRecord) updateHistory(signature string, date time.Time, height int64, l *zerolog.Logger) {
+	if signature == "" {
+		return
+	}
+
+	lastIdx := -1
+	for i := range record.History {
+		if record.History[i].Signature == signature {
+			entry := &record.History[i]
+			if date.After(entry.LastSeen) {
+				entry.LastSeen = date
+			}
+			if height > entry.LastHeight {
+				entry.LastHeight = height
+			}
+			entry.Count++
+			return
+		}
+		if lastIdx < 0 || record.History[i].LastSeen.After(record.History[lastIdx].LastSeen) {
+			lastIdx = i
+		}
+	}
+
+	// New signature
+	if lastIdx >= 0 {
+		previous := record.History[lastIdx]
+		l.Info().
+			Str("supplier_id", record.TaskData.SupplierID.String()).
+			Str("framework", record.TaskData.Framework).
+			Str("task", record.TaskData.Task).
+			Str("previous_signature", previous.Signature).
+			Str("new_signature", signature).
+			Msg("Signature changed.")
+		record.events = append(record.events, SupplierEvent{
+			SupplierID: record.TaskData.SupplierID,
+			Framework:  record.TaskData.Framework,
+			Task:       record.TaskData.Task,
+			EventType:  SupplierEventSignatureChange,
+			Date:       date,
+			Height:     height,
+			Details: map[string]interface{}{
+				"previous_signature":   previous.Signature,
+				"previous_last_seen":   previous.LastSeen,
+				"previous_last_height": previous.LastHeight,
+				"new_signature":        signature,
+				"known_signatures":     len(record.History),
+			},
+		})
+	}
+	record.History = append(record.History, SignatureHistoryEntry{
+		Signature:   signature,
+		FirstSeen:   date,
+		LastSeen:    date,
+		FirstHeight: height,
+		LastHeight:  height,
+		Count:       1,
+	})
+
+	// Keep the history bounded
+	if len(record.History) > SignatureMaxHistoryEntries {
+		oldestIdx := 0
+		for i := range record.History {
+			if record.History[i].LastSeen.Before(record.History[oldestIdx].LastSeen) {
+				oldestIdx = i
+			}
+		}
+		record.History = append(record.History[:oldestIdx], record.History[oldestIdx+1:]...)
+	}
+}
+
+// Returns the events produced since the last call and clears them
+func (record *SignatureTaskRecord) PopEvents() []SupplierEvent {
+	events := record.events
+	record.events = nil
+	return events
 }
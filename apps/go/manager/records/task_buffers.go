@@ -0,0 +1,223 @@
+package records
+
+import (
+	"context"
+	"manager/types"
+	"packages/mongodb"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ------------------------------------------------------------------------------
+// Batched task buffers of a supplier
+// ------------------------------------------------------------------------------
+
+type taskBufferKey struct {
+	framework string
+	task      string
+}
+
+type taskBufferChange struct {
+	collection string
+	// The buffer is not in the database yet
+	created bool
+}
+
+// All the task buffers of a supplier, loaded with one query per buffers
+// collection. The buffers are modified in memory and the changed ones are
+// written back with one bulk write per collection (see Save).
+type SupplierTaskBuffers struct {
+	supplierID primitive.ObjectID
+	configMap  map[string]types.FrameworkConfig
+	tasks      map[taskBufferKey]TaskInterface
+	// Changed buffers, to be written by Save
+	changed map[taskBufferKey]taskBufferChange
+}
+
+// Loads all the task buffers of the supplier. Buffers of framework-task pairs
+// that are no longer configured are skipped.
+func LoadSupplierTaskBuffers(supplierID primitive.ObjectID, configMap map[string]types.FrameworkConfig, mongoDB mongodb.MongoDb, l *zerolog.Logger) (*SupplierTaskBuffers, error) {
+
+	buffers := SupplierTaskBuffers{
+		supplierID: supplierID,
+		configMap:  configMap,
+		tasks:      make(map[taskBufferKey]TaskInterface),
+		changed:    make(map[taskBufferKey]taskBufferChange),
+	}
+
+	task_filter := bson.D{{Key: "task_data.supplier_id", Value: supplierID}}
+	for _, collection := range GetTaskTypesCollections() {
+		tasksCollection := mongoDB.GetCollection(collection)
+		ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		cursor, err := tasksCollection.Find(ctxM, task_filter)
+		if err != nil {
+			cancel()
+			l.Error().Err(err).Str("supplier_id", supplierID.String()).Str("collection", collection).Msg("Could not retrieve task buffers from MongoDB.")
+			return nil, err
+		}
+		for cursor.Next(ctxM) {
+			// Get the framework-task pair first, to know the task type
+			var header struct {
+				TaskData BaseTaskRecord `bson:"task_data"`
+			}
+			if err = cursor.Decode(&header); err != nil {
+				break
+			}
+			framework := header.TaskData.Framework
+			task := header.TaskData.Task
+			taskType, typeErr := GetTaskType(framework, task, configMap, l)
+			if typeErr != nil || getTaskTypeCollection(taskType) != collection {
+				l.Debug().Str("supplier_id", supplierID.String()).Str("framework", framework).Str("task", task).Msg("Task buffer not configured, skipping.")
+				continue
+			}
+			registration, _ := GetTaskTypeRegistration(taskType)
+			record := registration.NewTask()
+			if err = cursor.Decode(record); err != nil {
+				break
+			}
+			buffers.tasks[taskBufferKey{framework: framework, task: task}] = record
+		}
+		if err == nil {
+			err = cursor.Err()
+		}
+		cursor.Close(ctxM)
+		cancel()
+		if err != nil {
+			l.Error().Err(err).Str("supplier_id", supplierID.String()).Str("collection", collection).Msg("Could not decode task buffers from MongoDB.")
+			return nil, err
+		}
+	}
+
+	l.Debug().Str("supplier_id", supplierID.String()).Int("buffers", len(buffers.tasks)).Msg("Task buffers loaded.")
+
+	return &buffers, nil
+}
+
+// Same as GetTaskData, but using the loaded buffers. New buffers are only
+// created in memory and written by Save.
+func (buffers *SupplierTaskBuffers) GetTaskData(taskType string, framework string, task string, create_new bool, l *zerolog.Logger) (TaskInterface, bool) {
+
+	// Get the task type constructor
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		l.Error().
+			Str("supplierID", buffers.supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Str("task_type", taskType).
+			Msg("task type not registered")
+		return nil, false
+	}
+
+	// Get the buffer sizing of this framework-task
+	bufferCfg, err := GetTaskBufferConfig(framework, task, taskType, buffers.configMap)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("invalid buffer configuration")
+		return nil, false
+	}
+
+	key := taskBufferKey{framework: framework, task: task}
+	record, found := buffers.tasks[key]
+	if !found {
+		if !create_new {
+			return nil, false
+		}
+		record = registration.NewTask()
+	}
+
+	// Set the buffer config, this will resize existing buffers if the
+	// configured length changed
+	resized, err := record.SetBufferConfig(bufferCfg, l)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("supplierID", buffers.supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("cannot resize task buffer")
+		return nil, false
+	}
+	if !found {
+		// Initialize, it will be saved with the other changes
+		record.NewTask(buffers.supplierID, framework, task, types.EpochStart.UTC(), l)
+		buffers.tasks[key] = record
+	}
+	if resized || !found {
+		buffers.changed[key] = taskBufferChange{collection: registration.Collection, created: !found}
+	}
+
+	return record, true
+}
+
+// Marks a buffer as changed, so it is written by Save
+func (buffers *SupplierTaskBuffers) SetChanged(taskType string, framework string, task string) {
+	key := taskBufferKey{framework: framework, task: task}
+	if _, ok := buffers.tasks[key]; !ok {
+		return
+	}
+	if change, ok := buffers.changed[key]; ok && change.created {
+		// Keep it as a new buffer
+		return
+	}
+	buffers.changed[key] = taskBufferChange{collection: getTaskTypeCollection(taskType)}
+}
+
+// Writes all the changed buffers, with one bulk write per collection.
+// The results processing writes to the same records while the analysis runs,
+// so an existing buffer is only written if its version did not change since it
+// was loaded, otherwise the new samples would be overwritten. Skipped buffers
+// are cycled again by the next analysis. New buffers are only inserted if no
+// other process created them in the meantime.
+func (buffers *SupplierTaskBuffers) Save(mongoDB mongodb.MongoDb, l *zerolog.Logger) error {
+
+	models := make(map[string][]mongo.WriteModel)
+	for key, change := range buffers.changed {
+		record := buffers.tasks[key]
+		task_filter := bson.D{
+			{Key: "task_data.supplier_id", Value: buffers.supplierID},
+			{Key: "task_data.framework", Value: key.framework},
+			{Key: "task_data.task", Value: key.task},
+		}
+		var model *mongo.UpdateOneModel
+		if change.created {
+			update := bson.D{{Key: "$setOnInsert", Value: record}}
+			model = mongo.NewUpdateOneModel().SetFilter(task_filter).SetUpdate(update).SetUpsert(true)
+		} else {
+			task_filter = append(task_filter, taskVersionFilter(record.GetVersion()))
+			record.BumpVersion()
+			update := bson.D{{Key: "$set", Value: record}}
+			model = mongo.NewUpdateOneModel().SetFilter(task_filter).SetUpdate(update)
+		}
+		models[change.collection] = append(models[change.collection], model)
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+	for collection, collectionModels := range models {
+		tasksCollection := mongoDB.GetCollection(collection)
+		ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		result, err := tasksCollection.BulkWrite(ctxM, collectionModels, opts)
+		cancel()
+		if err != nil {
+			l.Error().Err(err).Str("supplier_id", buffers.supplierID.String()).Str("collection", collection).Msg("Could not write task buffers to MongoDB.")
+			return err
+		}
+		// Buffers that were not matched (nor upserted) changed after loading
+		skipped := int64(len(collectionModels)) - result.MatchedCount - result.UpsertedCount
+		if skipped > 0 {
+			l.Info().Str("supplier_id", buffers.supplierID.String()).Str("collection", collection).Int64("skipped", skipped).Msg("Task buffers changed since loaded, not saved.")
+		}
+		l.Debug().Str("supplier_id", buffers.supplierID.String()).Str("collection", collection).Int("buffers", len(collectionModels)).Msg("Task buffers saved.")
+	}
+	buffers.changed = make(map[taskBufferKey]taskBufferChange)
+
+	return nil
+}
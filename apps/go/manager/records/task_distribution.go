@@ -0,0 +1,294 @@
+package records
+
+import (
+	"context"
+	"manager/types"
+	"math"
+	"packages/mongodb"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gonum.org/v1/gonum/stat"
+)
+
+// ------------------------------------------------------------------------------
+// DistributionTaskRecord
+// ------------------------------------------------------------------------------
+
+const DistributionTaskTypeName string = "distribution"
+
+// Quantiles need more samples than averages to be meaningful (a p99 with less
+// than 100 samples is just the maximum), so the default buffer is larger than
+// the numerical one.
+const DistributionSampleTTLDays uint32 = 32
+const DistributionMinSamplesPerTask uint32 = 100
+const DistributionMaxConcurrentSamplesPerTask uint32 = 2
+const DistributionCircularBufferLength uint32 = 200
+
+// Upper bounds of the score histogram bins, scores are expected in [0,1]. An
+// extra bin with an infinite upper bound catches everything else.
+var DistributionScoreBins = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1.0}
+
+// Upper bounds of the run time histogram bins, in milliseconds.
+var DistributionRunTimeBins = []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000}
+
+func init() {
+	MustRegisterTaskType(TaskTypeRegistration{
+		Name:       DistributionTaskTypeName,
+		Collection: types.DistributionTaskCollection,
+		NewTask:    func() TaskInterface { return &DistributionTaskRecord{} },
+		NewResult:  func() ResultInterface { return &NumericalResultRecord{} },
+		BufferDefaults: types.TaskBufferConfig{
+			CircularBufferLength:        DistributionCircularBufferLength,
+			MinSamplesPerTask:           DistributionMinSamplesPerTask,
+			MaxConcurrentSamplesPerTask: DistributionMaxConcurrentSamplesPerTask,
+			SampleTTLDays:               DistributionSampleTTLDays,
+		},
+		Metrics: DistributionMetrics,
+	})
+}
+
+// A numerical task that also keeps the distribution of scores and run times.
+// The samples are the same as in the numerical task (and so are the results
+// written by the evaluator), the quantiles and histograms are re-calculated
+// over the valid window of the circular buffer on each processing, so they
+// follow the same TTL as the rest of the metrics.
+type DistributionTaskRecord struct {
+	NumericalTaskRecord `bson:",inline"`
+	// Quantiles
+	ScoreQuantiles   Quantiles `bson:"score_quantiles"`
+	RunTimeQuantiles Quantiles `bson:"run_time_quantiles"`
+	// Tail latency
+	MaxRunTime       float32 `bson:"max_times"`
+	TailLatencyRatio float32 `bson:"tail_latency_ratio"`
+	// Histograms
+	ScoreHistogram   []HistogramBin `bson:"score_histogram"`
+	RunTimeHistogram []HistogramBin `bson:"run_time_histogram"`
+}
+
+type Quantiles struct {
+	P50 float32 `bson:"p50"`
+	P90 float32 `bson:"p90"`
+	P95 float32 `bson:"p95"`
+	P99 float32 `bson:"p99"`
+}
+
+type HistogramBin struct {
+	// Upper bound of the bin (inclusive), the last bin is +Inf
+	UpperBound float64 `bson:"le"`
+	Count      uint32  `bson:"count"`
+}
+
+func (record *DistributionTaskRecord) NewTask(supplierID primitive.ObjectID, framework string, task string, date time.Time, l *zerolog.Logger) {
+	record.NumericalTaskRecord.NewTask(supplierID, framework, task, date, l)
+
+	record.ScoreQuantiles = Quantiles{}
+	record.RunTimeQuantiles = Quantiles{}
+	record.MaxRunTime = 0.0
+	record.TailLatencyRatio = 0.0
+	record.ScoreHistogram = buildHistogram(nil, DistributionScoreBins)
+	record.RunTimeHistogram = buildHistogram(nil, DistributionRunTimeBins)
+}
+
+func (record *DistributionTaskRecord) LoadTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
+
+	task_filter := bson.D{{Key: "task_data.supplier_id", Value: supplierID}, {Key: "task_data.framework", Value: framework}, {Key: "task_data.task", Value: task}}
+	tasksCollection := mongoDB.GetCollection(getTaskTypeCollection(DistributionTaskTypeName))
+	opts := options.FindOne()
+
+	// Set mongo context
+	ctxM, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// Retrieve this supplier entry
+	var found bool = true
+	cursor := tasksCollection.FindOne(ctxM, task_filter, opts)
+	err := cursor.Decode(record)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			l.Debug().Str("supplier_id", supplierID.String()).Str("framework", framework).Str("task", task).Msg("Distribution Task not found")
+			found = false
+		} else {
+			l.Error().Err(err).Msg("Could not retrieve task data from MongoDB.")
+			return false, err
+		}
+	}
+
+	return found, nil
+}
+
+func (record *DistributionTaskRecord) UpdateTask(supplierID primitive.ObjectID, framework string, task string, mongoDB mongodb.MongoDb, l *zerolog.Logger) (bool, error) {
+
+	found, err := updateTaskRecord(DistributionTaskTypeName, record, supplierID, framework, task, mongoDB, l)
+	if err != nil {
+		l.Error().
+			Err(err).
+			Str("supplier_id", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("Could not update distribution task data in MongoDB.")
+		return false, err
+	}
+	if !found {
+		l.Warn().
+			Str("supplier_id", supplierID.String()).
+			Str("framework", framework).
+			Str("task", task).
+			Msg("Distribution Task not found, creating one.")
+	}
+
+	return found, nil
+}
+
+func (record *DistributionTaskRecord) GetMinSamplesPerTask() uint32 {
+	if record.TaskData.BufferConfig.MinSamplesPerTask == 0 {
+		return DistributionMinSamplesPerTask
+	}
+	return record.TaskData.BufferConfig.MinSamplesPerTask
+}
+
+func (record *DistributionTaskRecord) GetMaxConcurrentSamplesPerTask() uint32 {
+	if record.TaskData.BufferConfig.MaxConcurrentSamplesPerTask == 0 {
+		return DistributionMaxConcurrentSamplesPerTask
+	}
+	return record.TaskData.BufferConfig.MaxConcurrentSamplesPerTask
+}
+
+func (record *DistributionTaskRecord) GetSampleTTLDays() uint32 {
+	if record.TaskData.BufferConfig.SampleTTLDays == 0 {
+		return DistributionSampleTTLDays
+	}
+	return record.TaskData.BufferConfig.SampleTTLDays
+}
+
+func (record *DistributionTaskRecord) GetCircularBufferLength() uint32 {
+	if record.TaskData.BufferConfig.CircularBufferLength == 0 {
+		return DistributionCircularBufferLength
+	}
+	return record.TaskData.BufferConfig.CircularBufferLength
+}
+
+// Updates the indexes making them point to the initial and final samples in a given time window.
+func (record *DistributionTaskRecord) CycleIndexes(l *zerolog.Logger) (bool, error) {
+	return record.CircBuffer.CycleIndexes(record.GetSampleTTLDays(), l)
+}
+
+// Calculate task statistics, the numerical ones plus the distributions
+func (record *DistributionTaskRecord) ProcessData(l *zerolog.Logger) (err error) {
+
+	// Mean, median, std and errors
+	err = record.NumericalTaskRecord.ProcessData(l)
+	if err != nil {
+		return err
+	}
+
+	// Get valid samples
+	validIdx, err := record.CircBuffer.GetBufferValidIndexes(l)
+	if err != nil {
+		return err
+	}
+
+	// Only successful samples are part of the distributions
+	var auxDataScores []float64
+	var auxDataTimes []float64
+	for _, sampleId := range validIdx {
+		if record.ScoresSamples[sampleId].StatusCode == 0 {
+			auxDataScores = append(auxDataScores, float64(record.ScoresSamples[sampleId].Score))
+			auxDataTimes = append(auxDataTimes, float64(record.ScoresSamples[sampleId].RunTime))
+		}
+	}
+	sort.Float64s(auxDataScores)
+	sort.Float64s(auxDataTimes)
+
+	record.ScoreQuantiles = calculateQuantiles(auxDataScores)
+	record.RunTimeQuantiles = calculateQuantiles(auxDataTimes)
+	record.ScoreHistogram = buildHistogram(auxDataScores, DistributionScoreBins)
+	record.RunTimeHistogram = buildHistogram(auxDataTimes, DistributionRunTimeBins)
+
+	record.MaxRunTime = 0.0
+	record.TailLatencyRatio = 0.0
+	if len(auxDataTimes) > 0 {
+		record.MaxRunTime = float32(auxDataTimes[len(auxDataTimes)-1])
+		if record.RunTimeQuantiles.P50 > 0 {
+			record.TailLatencyRatio = record.RunTimeQuantiles.P99 / record.RunTimeQuantiles.P50
+		}
+	}
+
+	return nil
+}
+
+// Metrics that can be used in the dependency rules of distribution tasks, all
+// the numerical ones plus the quantiles
+var DistributionMetrics = append([]string{
+	"p50_score",
+	"p90_score",
+	"p95_score",
+	"p99_score",
+	"p50_time",
+	"p90_time",
+	"p95_time",
+	"p99_time",
+	"max_time",
+	"tail_latency_ratio",
+}, NumericalMetrics...)
+
+// Returns the value of a named metric of the task
+func (record *DistributionTaskRecord) GetMetric(name string) (value float64, ok bool) {
+	switch name {
+	case "p50_score":
+		return float64(record.ScoreQuantiles.P50), true
+	case "p90_score":
+		return float64(record.ScoreQuantiles.P90), true
+	case "p95_score":
+		return float64(record.ScoreQuantiles.P95), true
+	case "p99_score":
+		return float64(record.ScoreQuantiles.P99), true
+	case "p50_time":
+		return float64(record.RunTimeQuantiles.P50), true
+	case "p90_time":
+		return float64(record.RunTimeQuantiles.P90), true
+	case "p95_time":
+		return float64(record.RunTimeQuantiles.P95), true
+	case "p99_time":
+		return float64(record.RunTimeQuantiles.P99), true
+	case "max_time":
+		return float64(record.MaxRunTime), true
+	case "tail_latency_ratio":
+		return float64(record.TailLatencyRatio), true
+	}
+	return record.NumericalTaskRecord.GetMetric(name)
+}
+
+// Calculates the quantiles of an already sorted array
+func calculateQuantiles(sortedData []float64) Quantiles {
+	if len(sortedData) == 0 {
+		return Quantiles{}
+	}
+	return Quantiles{
+		P50: float32(stat.Quantile(0.50, stat.Empirical, sortedData, nil)),
+		P90: float32(stat.Quantile(0.90, stat.Empirical, sortedData, nil)),
+		P95: float32(stat.Quantile(0.95, stat.Empirical, sortedData, nil)),
+		P99: float32(stat.Quantile(0.99, stat.Empirical, sortedData, nil)),
+	}
+}
+
+// Counts the data in the given bins, adding a last bin for the values larger
+// than the last upper bound.
+func buildHistogram(data []float64, upperBounds []float64) []HistogramBin {
+	histogram := make([]HistogramBin, len(upperBounds)+1)
+	for i, bound := range upperBounds {
+		histogram[i].UpperBound = bound
+	}
+	histogram[len(upperBounds)].UpperBound = math.Inf(1)
+
+	for _, value := range data {
+		binIdx := sort.SearchFloat64s(upperBounds, value)
+		histogram[binIdx].Count += 1
+	}
+	return histogram
+}
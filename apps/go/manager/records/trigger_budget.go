@@ -0,0 +1,176 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+	"sort"
+	"time"
+)
+
+//------------------------------------------------------------------------------
+// Global trigger budget
+//------------------------------------------------------------------------------
+
+// Hours since the last sample of a task to consider its buffer stale, used if
+// the budget does not set it
+const TriggerBudgetDefaultStaleHours float64 = 24
+
+// Priority tiers of the triggers, the budget is spent on the lower tiers first
+const (
+	triggerTierNewSupplier = iota
+	triggerTierStale
+	triggerTierRest
+	numTriggerTiers
+)
+
+// Returns the samples budget of a run of the given service, zero if there is
+// no limit
+func GetTriggerBudget(cfg *types.TriggerBudgetConfig, service string) uint32 {
+	if cfg == nil {
+		return 0
+	}
+	budget := cfg.SamplesPerRun
+	serviceBudget, ok := cfg.SamplesPerService[service]
+	if !ok {
+		serviceBudget = cfg.SamplesPerService["any"]
+	}
+	if serviceBudget > 0 && (budget == 0 || serviceBudget < budget) {
+		budget = serviceBudget
+	}
+	return budget
+}
+
+// Returns the priority tier of a trigger
+func getTriggerTier(trigger types.TaskTrigger, staleBefore time.Time) int {
+	if trigger.NewSupplier {
+		return triggerTierNewSupplier
+	}
+	if trigger.LastSeen.Before(staleBefore) {
+		return triggerTierStale
+	}
+	return triggerTierRest
+}
+
+// Limits the samples of the triggers to the given budget (zero for no limit).
+// In each priority tier the samples are given one at a time, cycling over the
+// suppliers and, for each supplier, over its tasks from the stalest one, so
+// no supplier gets a second sample before all others got their first.
+// Returns the triggers to send, with their quantities reduced, and the number
+// of samples left out.
+func ApplyTriggerBudget(triggers []types.TaskTrigger, budget uint32, staleHours float64, now time.Time) (kept []types.TaskTrigger, deferred uint) {
+
+	if budget == 0 {
+		return triggers, 0
+	}
+	if staleHours == 0 {
+		staleHours = TriggerBudgetDefaultStaleHours
+	}
+	staleBefore := now.Add(-time.Duration(staleHours * float64(time.Hour)))
+
+	// Sort to get a deterministic allocation: by supplier, and by task age
+	// within each supplier
+	ordered := make([]int, len(triggers))
+	for i := range ordered {
+		ordered[i] = i
+	}
+	sort.SliceStable(ordered, func(a, b int) bool {
+		ta, tb := triggers[ordered[a]], triggers[ordered[b]]
+		if ta.Address != tb.Address {
+			return ta.Address < tb.Address
+		}
+		if !ta.LastSeen.Equal(tb.LastSeen) {
+			return ta.LastSeen.Before(tb.LastSeen)
+		}
+		if ta.Framework != tb.Framework {
+			return ta.Framework < tb.Framework
+		}
+		return ta.Task < tb.Task
+	})
+
+	// Group the triggers by tier and supplier, keeping the order
+	type supplierQueue struct {
+		triggers []int
+		next     int
+	}
+	tiers := make([][]*supplierQueue, numTriggerTiers)
+	for tier := range tiers {
+		queues := make([]*supplierQueue, 0)
+		byAddress := make(map[string]*supplierQueue)
+		for _, idx := range ordered {
+			if getTriggerTier(triggers[idx], staleBefore) != tier {
+				continue
+			}
+			queue, ok := byAddress[triggers[idx].Address]
+			if !ok {
+				queue = &supplierQueue{}
+				byAddress[triggers[idx].Address] = queue
+				queues = append(queues, queue)
+			}
+			queue.triggers = append(queue.triggers, idx)
+		}
+		tiers[tier] = queues
+	}
+
+	// Round-robin over the suppliers of each tier
+	allocated := make([]int, len(triggers))
+	remaining := budget
+	for _, queues := range tiers {
+		for remaining > 0 {
+			given := false
+			for _, queue := range queues {
+				if remaining == 0 {
+					break
+				}
+				// Next task of the supplier that still wants samples
+				for tries := 0; tries < len(queue.triggers); tries++ {
+					idx := queue.triggers[queue.next]
+					queue.next = (queue.next + 1) % len(queue.triggers)
+					if allocated[idx] < triggers[idx].Qty {
+						allocated[idx]++
+						remaining--
+						given = true
+						break
+					}
+				}
+			}
+			if !given {
+				// This tier is satisfied
+				break
+			}
+		}
+	}
+
+	kept = make([]types.TaskTrigger, 0, len(triggers))
+	for idx, trigger := range triggers {
+		if trigger.Qty > allocated[idx] {
+			deferred += uint(trigger.Qty - allocated[idx])
+		}
+		if allocated[idx] == 0 {
+			continue
+		}
+		trigger.Qty = allocated[idx]
+		kept = append(kept, trigger)
+	}
+
+	return kept, deferred
+}
+
+// Checks the trigger budget configuration, a nil configuration disables the
+// budget.
+func ValidateTriggerBudgetConfig(cfg *types.TriggerBudgetConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	if cfg.StaleHours < 0 {
+		return fmt.Errorf("stale_hours cannot be negative")
+	}
+	if cfg.SamplesPerRun == 0 {
+		for _, budget := range cfg.SamplesPerService {
+			if budget > 0 {
+				return nil
+			}
+		}
+		return fmt.Errorf("samples_per_run or samples_per_service must be set")
+	}
+	return nil
+}
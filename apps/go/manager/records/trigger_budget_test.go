@@ -0,0 +1,112 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyTriggerBudget(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	trigger := func(address string, task string, qty int, age time.Duration, newSupplier bool) types.TaskTrigger {
+		return types.TaskTrigger{
+			Address:     address,
+			Framework:   "lmeh",
+			Task:        task,
+			Qty:         qty,
+			NewSupplier: newSupplier,
+			LastSeen:    now.Add(-age),
+		}
+	}
+
+	tests := []struct {
+		name         string
+		triggers     []types.TaskTrigger
+		budget       uint32
+		staleHours   float64
+		want         []string
+		wantDeferred uint
+	}{
+		{
+			name:     "no budget",
+			triggers: []types.TaskTrigger{trigger("a", "t1", 3, time.Hour, false), trigger("b", "t1", 5, time.Hour, false)},
+			want:     []string{"a/t1:3", "b/t1:5"},
+		},
+		{
+			name:     "budget over the demand",
+			triggers: []types.TaskTrigger{trigger("a", "t1", 3, time.Hour, false), trigger("b", "t1", 5, time.Hour, false)},
+			budget:   20,
+			want:     []string{"a/t1:3", "b/t1:5"},
+		},
+		{
+			name: "round robin over suppliers and their tasks",
+			triggers: []types.TaskTrigger{
+				trigger("a", "t1", 3, time.Hour, false),
+				trigger("a", "t2", 3, time.Hour, false),
+				trigger("b", "t1", 3, time.Hour, false),
+			},
+			budget:       4,
+			want:         []string{"a/t1:1", "a/t2:1", "b/t1:2"},
+			wantDeferred: 5,
+		},
+		{
+			name: "stalest task of a supplier first",
+			triggers: []types.TaskTrigger{
+				trigger("a", "t1", 3, time.Hour, false),
+				trigger("a", "t2", 3, 2*time.Hour, false),
+			},
+			budget:       1,
+			want:         []string{"a/t2:1"},
+			wantDeferred: 5,
+		},
+		{
+			name: "new suppliers first",
+			triggers: []types.TaskTrigger{
+				trigger("a", "t1", 3, 48*time.Hour, false),
+				trigger("b", "t1", 3, time.Hour, true),
+			},
+			budget:       2,
+			want:         []string{"b/t1:2"},
+			wantDeferred: 4,
+		},
+		{
+			name: "stale buffers before the rest",
+			triggers: []types.TaskTrigger{
+				trigger("a", "t1", 3, time.Hour, false),
+				trigger("b", "t1", 3, 48*time.Hour, false),
+			},
+			budget:       2,
+			want:         []string{"b/t1:2"},
+			wantDeferred: 4,
+		},
+		{
+			name: "stale hours from the config",
+			triggers: []types.TaskTrigger{
+				trigger("a", "t1", 3, time.Hour, false),
+				trigger("b", "t1", 3, 3*time.Hour, false),
+			},
+			budget:       2,
+			staleHours:   2,
+			want:         []string{"b/t1:2"},
+			wantDeferred: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, deferred := ApplyTriggerBudget(tt.triggers, tt.budget, tt.staleHours, now)
+			got := make([]string, len(kept))
+			for i, trigger := range kept {
+				got[i] = fmt.Sprintf("%s/%s:%d", trigger.Address, trigger.Task, trigger.Qty)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+			if deferred != tt.wantDeferred {
+				t.Errorf("deferred %d, want %d", deferred, tt.wantDeferred)
+			}
+		})
+	}
+}
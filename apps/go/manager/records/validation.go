@@ -0,0 +1,138 @@
+package records
+
+import (
+	"fmt"
+	"manager/types"
+
+	"github.com/rs/zerolog"
+)
+
+// ------------------------------------------------------------------------------
+// Frameworks configuration validation
+// ------------------------------------------------------------------------------
+
+// Checks the frameworks configuration once, at startup, so that malformed
+// entries are reported before any workflow is executed.
+func ValidateFrameworksConfig(configMap map[string]types.FrameworkConfig) error {
+	err := ValidateTaskTypes(configMap)
+	if err != nil {
+		return err
+	}
+	err = ValidateBufferConfigs(configMap)
+	if err != nil {
+		return err
+	}
+	err = ValidateDependencyRules(configMap)
+	if err != nil {
+		return err
+	}
+	err = ValidateEvaluationPolicies(configMap)
+	if err != nil {
+		return err
+	}
+	return ValidateDependencyGraph(configMap)
+}
+
+// Checks that all task types named in the frameworks configuration are
+// registered.
+func ValidateTaskTypes(configMap map[string]types.FrameworkConfig) error {
+	for framework, frameworkCfg := range configMap {
+		if len(frameworkCfg.TasksTypes) == 0 {
+			return fmt.Errorf("framework %s: no task types defined", framework)
+		}
+		for task, taskType := range frameworkCfg.TasksTypes {
+			if _, ok := GetTaskTypeRegistration(taskType); !ok {
+				return fmt.Errorf("framework %s, task %s: unknown task type %q (registered: %v)",
+					framework, task, taskType, GetRegisteredTaskTypes())
+			}
+		}
+	}
+	return nil
+}
+
+// Checks that the buffer configuration resolves to valid values for all the
+// configured entries of each framework.
+func ValidateBufferConfigs(configMap map[string]types.FrameworkConfig) error {
+	for framework, frameworkCfg := range configMap {
+		// Check "any" and every task with a specific entry
+		tasks := []string{"any"}
+		for task := range frameworkCfg.BufferConfig {
+			tasks = append(tasks, task)
+		}
+		for _, task := range tasks {
+			taskType, err := GetTaskType(framework, task, configMap, &nopLogger)
+			if err != nil {
+				// Tasks without type cannot be checked, this is reported by the
+				// task types check when needed
+				continue
+			}
+			_, err = GetTaskBufferConfig(framework, task, taskType, configMap)
+			if err != nil {
+				return fmt.Errorf("framework %s, task %s: %s", framework, task, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// Resolves the buffer configuration of a framework-task pair. The specific task
+// entry has priority, then the "any" entry and finally the task type defaults.
+func GetTaskBufferConfig(framework string, task string, taskType string, configMap map[string]types.FrameworkConfig) (types.TaskBufferConfig, error) {
+
+	registration, ok := GetTaskTypeRegistration(taskType)
+	if !ok {
+		return types.TaskBufferConfig{}, fmt.Errorf("task type %s not registered", taskType)
+	}
+	bufferCfg := registration.BufferDefaults
+
+	frameworkCfg, ok := configMap[framework]
+	if ok {
+		if anyCfg, ok := frameworkCfg.BufferConfig["any"]; ok {
+			bufferCfg = anyCfg.WithFallback(bufferCfg)
+		}
+		if taskCfg, ok := frameworkCfg.BufferConfig[task]; ok {
+			bufferCfg = taskCfg.WithFallback(bufferCfg)
+		}
+	}
+
+	err := validateBufferConfig(bufferCfg)
+	if err != nil {
+		return types.TaskBufferConfig{}, err
+	}
+
+	return bufferCfg, nil
+}
+
+func validateBufferConfig(bufferCfg types.TaskBufferConfig) error {
+	if bufferCfg.CircularBufferLength == 0 {
+		return fmt.Errorf("circular_buffer_length must be larger than zero")
+	}
+	if bufferCfg.SampleTTLDays == 0 {
+		return fmt.Errorf("sample_ttl_days must be larger than zero")
+	}
+	if bufferCfg.MaxConcurrentSamplesPerTask == 0 {
+		return fmt.Errorf("max_concurrent_samples_per_task must be larger than zero")
+	}
+	if bufferCfg.GetDecayHalfLifeDays() < 0 {
+		return fmt.Errorf("decay_half_life_days cannot be negative")
+	}
+	switch bufferCfg.ConsensusMode {
+	case "", SignatureConsensusLast, SignatureConsensusMajority:
+	default:
+		return fmt.Errorf("unknown consensus_mode %q (expected %q or %q)", bufferCfg.ConsensusMode, SignatureConsensusLast, SignatureConsensusMajority)
+	}
+	if bufferCfg.GetMinAgreement() < 0 || bufferCfg.GetMinAgreement() >= 1 {
+		return fmt.Errorf("min_agreement must be in the [0, 1) range")
+	}
+	if err := validateErrorCodes(bufferCfg.ErrorCodes); err != nil {
+		return err
+	}
+	if bufferCfg.MinSamplesPerTask > bufferCfg.CircularBufferLength {
+		return fmt.Errorf("min_samples_per_task (%d) cannot be larger than circular_buffer_length (%d)",
+			bufferCfg.MinSamplesPerTask, bufferCfg.CircularBufferLength)
+	}
+	return nil
+}
+
+// Used where a logger is optional
+var nopLogger = zerolog.Nop()
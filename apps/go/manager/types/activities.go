@@ -1,6 +1,10 @@
 package types
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 //------------------------------------------------------------------------------
 // Get Staked Suppliers
@@ -50,6 +54,9 @@ type TaskTrigger struct {
 	Blacklist  []int  `bson:"blacklist"`
 	Qty        int    `bson:"qty"`
 	RandomSeed int    `bson:"random_seed"`
+	// Used to prioritize the triggers when the budget is limited
+	NewSupplier bool      `bson:"new_supplier"`
+	LastSeen    time.Time `bson:"last_seen"`
 }
 
 //------------------------------------------------------------------------------
@@ -75,3 +82,88 @@ type AnalyzeResultParams struct {
 type AnalyzeResultResults struct {
 	Success bool `json:"success"`
 }
+
+//------------------------------------------------------------------------------
+// Find Duplicate Responses
+//------------------------------------------------------------------------------
+
+type FindDuplicateResponsesParams struct {
+	Since             time.Time `json:"since"`
+	MinSharedDocs     uint      `json:"min_shared_docs"`
+	MinMatchRate      float64   `json:"min_match_rate"`
+	MinResponseLength uint      `json:"min_response_length"`
+}
+
+type FindDuplicateResponsesResults struct {
+	Samples       uint `json:"samples"`
+	ComparedPairs uint `json:"compared_pairs"`
+	FlaggedPairs  uint `json:"flagged_pairs"`
+}
+
+//------------------------------------------------------------------------------
+// Cluster Backends
+//------------------------------------------------------------------------------
+
+type ClusterBackendsParams struct {
+	Since         time.Time `json:"since"`
+	MinSimilarity float64   `json:"min_similarity"`
+	MinComponents uint      `json:"min_components"`
+}
+
+type ClusterBackendsResults struct {
+	Suppliers        uint `json:"suppliers"`
+	Groups           uint `json:"groups"`
+	GroupedSuppliers uint `json:"grouped_suppliers"`
+}
+
+//------------------------------------------------------------------------------
+// Reap Stale Tasks
+//------------------------------------------------------------------------------
+
+type ReapStaleTasksParams struct {
+	// Tasks created before this date and not done are reaped
+	OlderThan time.Time `json:"older_than"`
+	BatchSize uint      `json:"batch_size"`
+}
+
+type ReapStaleTasksResults struct {
+	Reaped         uint `json:"reaped"`
+	TimeoutSamples uint `json:"timeout_samples"`
+	// Tasks removed without recording their timeouts (unknown supplier or
+	// task no longer configured)
+	Unrecorded uint `json:"unrecorded"`
+	// Tasks kept because their timeouts could not be recorded (DB errors),
+	// they are retried by the next run
+	Skipped uint `json:"skipped"`
+	// True if the batch was full and made progress, there can be more stale
+	// tasks
+	More bool `json:"more"`
+}
+
+//------------------------------------------------------------------------------
+// Clean Orphans
+//------------------------------------------------------------------------------
+
+type CleanOrphansParams struct {
+	Collection string `json:"collection"`
+	// Only documents created before this date are checked, newer ones can
+	// belong to a task being written
+	OlderThan time.Time `json:"older_than"`
+	// Documents are scanned in _id order, starting after this one
+	AfterID   primitive.ObjectID `json:"after_id"`
+	BatchSize uint               `json:"batch_size"`
+	// Only report the orphans, without removing them
+	DryRun bool `json:"dry_run"`
+}
+
+type CleanOrphansResults struct {
+	Scanned uint `json:"scanned"`
+	Orphans uint `json:"orphans"`
+	Removed uint `json:"removed"`
+	// Distinct (removed) task IDs referenced by the orphans
+	OrphanTaskIDs []primitive.ObjectID `json:"orphan_task_ids"`
+	// Last scanned document, to continue from it
+	LastID primitive.ObjectID `json:"last_id"`
+	// True if the batch was full, there can be more documents to scan
+	More bool `json:"more"`
+}
@@ -209,3 +209,43 @@ func (buffer *CircularBuffer) GetBufferValidIndexes(l *zerolog.Logger) (auxIdx [
 	}
 	return auxIdx, err
 }
+
+// Resize changes the length of the buffer, keeping the most recent valid
+// samples that fit in the new length. The kept samples are packed at the start
+// of the buffer, in time order. The returned slice contains, for each position
+// of the new buffer, the index that the sample had in the old buffer, so the
+// owner of the buffer can move its own data arrays accordingly.
+func (buffer *CircularBuffer) Resize(newLen uint32, l *zerolog.Logger) (keptIdx []uint32, err error) {
+
+	if newLen == 0 {
+		return nil, errors.New("buffer: cannot resize to zero length")
+	}
+
+	validIdx, err := buffer.GetBufferValidIndexes(l)
+	if err != nil {
+		return nil, err
+	}
+	// Keep only the newest samples
+	if uint32(len(validIdx)) > newLen {
+		validIdx = validIdx[uint32(len(validIdx))-newLen:]
+	}
+
+	newTimes := make([]time.Time, newLen)
+	for i := range newTimes {
+		newTimes[i] = EpochStart
+	}
+	for i, idx := range validIdx {
+		newTimes[i] = buffer.Times[idx]
+	}
+
+	buffer.CircBufferLen = newLen
+	buffer.Times = newTimes
+	buffer.NumSamples = uint32(len(validIdx))
+	buffer.Indexes.Start = 0
+	buffer.Indexes.End = 0
+	if len(validIdx) > 0 {
+		buffer.Indexes.End = uint32(len(validIdx)) - 1
+	}
+
+	return validIdx, nil
+}
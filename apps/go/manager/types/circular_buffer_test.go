@@ -0,0 +1,121 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Creates a buffer of the given length, with a sample (one hour apart, in
+// index order) in each of the given positions
+func newTestBuffer(length uint32, start uint32, end uint32, written []uint32) CircularBuffer {
+	buffer := CircularBuffer{
+		CircBufferLen: length,
+		Times:         make([]time.Time, length),
+		Indexes:       CircularIndexes{Start: start, End: end},
+	}
+	for i := range buffer.Times {
+		buffer.Times[i] = EpochStart
+	}
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, idx := range written {
+		buffer.Times[idx] = base.Add(time.Duration(idx) * time.Hour)
+	}
+	buffer.NumSamples = uint32(len(written))
+	return buffer
+}
+
+func TestCircularBufferResize(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  CircularBuffer
+		newLen  uint32
+		wantIdx []uint32
+		wantErr bool
+	}{
+		{
+			name:    "shrink keeps the newest samples",
+			buffer:  newTestBuffer(5, 0, 4, []uint32{0, 1, 2, 3, 4}),
+			newLen:  3,
+			wantIdx: []uint32{2, 3, 4},
+		},
+		{
+			name:    "shrink a wrapped buffer",
+			buffer:  newTestBuffer(5, 3, 1, []uint32{3, 4, 0, 1}),
+			newLen:  2,
+			wantIdx: []uint32{0, 1},
+		},
+		{
+			name:    "grow packs the samples at the start",
+			buffer:  newTestBuffer(5, 3, 0, []uint32{3, 4, 0}),
+			newLen:  6,
+			wantIdx: []uint32{3, 4, 0},
+		},
+		{
+			name:    "samples never written are dropped",
+			buffer:  newTestBuffer(5, 0, 3, []uint32{0, 2, 3}),
+			newLen:  4,
+			wantIdx: []uint32{0, 2, 3},
+		},
+		{
+			name:    "empty buffer",
+			buffer:  newTestBuffer(5, 0, 0, nil),
+			newLen:  3,
+			wantIdx: nil,
+		},
+		{
+			name:    "zero length",
+			buffer:  newTestBuffer(5, 0, 1, []uint32{0, 1}),
+			newLen:  0,
+			wantErr: true,
+		},
+	}
+
+	l := zerolog.Nop()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldTimes := append([]time.Time(nil), tt.buffer.Times...)
+
+			keptIdx, err := tt.buffer.Resize(tt.newLen, &l)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keptIdx, tt.wantIdx) {
+				t.Errorf("kept indexes = %v, want %v", keptIdx, tt.wantIdx)
+			}
+
+			buffer := tt.buffer
+			if buffer.CircBufferLen != tt.newLen || uint32(len(buffer.Times)) != tt.newLen {
+				t.Fatalf("length = %d (%d times), want %d", buffer.CircBufferLen, len(buffer.Times), tt.newLen)
+			}
+			wantSamples := uint32(len(tt.wantIdx))
+			if buffer.NumSamples != wantSamples {
+				t.Errorf("num samples = %d, want %d", buffer.NumSamples, wantSamples)
+			}
+			wantEnd := uint32(0)
+			if wantSamples > 0 {
+				wantEnd = wantSamples - 1
+			}
+			if buffer.Indexes.Start != 0 || buffer.Indexes.End != wantEnd {
+				t.Errorf("indexes = %+v, want start 0 and end %d", buffer.Indexes, wantEnd)
+			}
+			for i, sampleTime := range buffer.Times {
+				want := EpochStart
+				if i < len(tt.wantIdx) {
+					want = oldTimes[tt.wantIdx[i]]
+				}
+				if !sampleTime.Equal(want) {
+					t.Errorf("time %d = %s, want %s", i, sampleTime, want)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"fmt"
+
 	shannon_types "packages/pocket_shannon/types"
 )
 
@@ -235,14 +237,213 @@ type Config struct {
 	Services               []string                   `json:"pocket_services"`
 	ExternalSuppliers      []string                   `json:"external_suppliers"`
 	TrackSuccessfulSamples bool                       `json:"track_successful_samples"`
+	Reputation             *ReputationConfig          `json:"reputation"`
+	Sampling               *SamplingConfig            `json:"sampling"`
+	TriggerBudget          *TriggerBudgetConfig       `json:"trigger_budget"`
+}
+
+// Limits of the samples (documents) sent to the sampler by each run of the
+// supplier manager. A sample can expand to several prompts (i.e. one per choice
+// of a loglikelihood document). The samples are shared fairly among the
+// suppliers, first among the
+// new suppliers, then among the tasks with stale buffers and then among the
+// rest. The triggers left out are requested again in the next run.
+type TriggerBudgetConfig struct {
+	// Maximum samples per run, zero for no limit
+	SamplesPerRun uint32 `json:"samples_per_run"`
+	// Maximum samples per run of the given service (or "any"), zero or not
+	// set for no limit
+	SamplesPerService map[string]uint32 `json:"samples_per_service"`
+	// Hours since the last sample of a task to consider its buffer stale
+	StaleHours float64 `json:"stale_hours"`
+}
+
+// Adaptive allocation of the samples requested for each supplier. Each time a
+// supplier is analyzed, up to supplier_budget samples are split among its
+// tasks, one at a time, to the task with the largest priority:
+//
+//	priority = std_error_weight * std_error + deficit_weight * deficit + threshold_weight * nearness
+//
+// where std_error is the standard error of the task score, deficit is the
+// fraction of min_samples_per_task still missing and nearness measures how
+// close the score is to the threshold of a dependency rule (1 when it is on
+// the threshold). If not set, the tasks are only filled up to their
+// min_samples_per_task.
+type SamplingConfig struct {
+	// Maximum number of samples requested per supplier and analysis, without
+	// the trigger_minimum ones
+	SupplierBudget  uint32  `json:"supplier_budget"`
+	StdErrorWeight  float64 `json:"std_error_weight"`
+	DeficitWeight   float64 `json:"deficit_weight"`
+	ThresholdWeight float64 `json:"threshold_weight"`
+	// Minimum priority of a task that already has min_samples_per_task samples
+	// to receive more
+	MinPriority float64 `json:"min_priority"`
+}
+
+// Weights of the components of the supplier reputation score. Each component
+// is a value in the [0, 1] range (larger is better) and the score is their
+// weighted mean, the components without data are not used.
+type ReputationConfig struct {
+	// Weight of the root node score of each taxonomy
+	TaxonomyWeights map[string]float64 `json:"taxonomy_weights"`
+	// Weight of the success rate (one minus the error rate) of the tasks
+	ErrorRateWeight float64 `json:"error_rate_weight"`
+	// Weight of the latency, the value of the latency metric of the tasks is
+	// scaled linearly from 1 (zero latency) to 0 (max_latency or more). The
+	// task times are in milliseconds, so max_latency is in milliseconds too.
+	LatencyWeight float64 `json:"latency_weight"`
+	LatencyMetric string  `json:"latency_metric"`
+	MaxLatency    float64 `json:"max_latency"`
+	// Weight of the 7 days uptime of the supplier
+	AvailabilityWeight float64 `json:"availability_weight"`
 }
 
 type FrameworkConfig struct {
-	TasksTypes         map[string]string   `json:"task_types"`
-	TasksDependency    map[string][]string `json:"task_dependency"`
-	ScheduleLimits     map[string]string   `json:"schedule_limits"`
-	TriggerMinimum     map[string]string   `json:"trigger_minimum"`
-	TaxonomyDependency map[string][]string `json:"taxonomy_dependency"`
+	TasksTypes         map[string]string                  `json:"task_types"`
+	TasksDependency    map[string][]DependencyRule        `json:"task_dependency"`
+	ScheduleLimits     map[string]ScheduleRule            `json:"schedule_limits"`
+	TriggerMinimum     map[string]string                  `json:"trigger_minimum"`
+	TaxonomyDependency map[string]TaxonomyDependencyRules `json:"taxonomy_dependency"`
+	BufferConfig       map[string]TaskBufferConfig        `json:"buffer_config"`
+	// If set, the taxonomy dependencies compare the lower bound of the root
+	// score confidence interval instead of the score itself
+	TaxonomyGateOnLowerBound bool `json:"taxonomy_gate_on_lower_bound"`
+	// Action taken for each non-zero evaluation result status, keyed by status
+	// code (or "any"): "retry", "supplier", "evaluator" or "ignore"
+	EvaluationStatusPolicy map[string]string `json:"evaluation_status_policy"`
+	// Maximum number of evaluation retries of a task, after that the failure is
+	// counted against the evaluator
+	MaxEvaluationRetries uint32 `json:"max_evaluation_retries"`
+	// How the random seed given to the sampler is chosen, see the
+	// SeedStrategy constants. Empty means SeedStrategyShared.
+	SeedStrategy string `json:"seed_strategy"`
+}
+
+// Random seed strategies of the sampler
+const (
+	// The same seed for all the triggers of a manager run, all suppliers get
+	// the same documents (needed by some signatures)
+	SeedStrategyShared = "shared"
+	// The run seed mixed with the supplier address
+	SeedStrategySupplier = "supplier"
+	// The run seed mixed with the supplier address and the task
+	SeedStrategyTask = "task"
+	// Derived from the supplier address and the session, so it does not change
+	// within a session and is reproducible without the run seed
+	SeedStrategySession = "session"
+)
+
+// Checks the structure of the dependency and schedule rules of the framework
+func (cfg FrameworkConfig) Validate() error {
+	for task, rules := range cfg.TasksDependency {
+		for idx, rule := range rules {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("task_dependency %s, entry %d: %s", task, idx, err.Error())
+			}
+		}
+	}
+	for task, rules := range cfg.TaxonomyDependency {
+		for idx, rule := range rules {
+			if err := rule.Validate(); err != nil {
+				return fmt.Errorf("taxonomy_dependency %s, entry %d: %s", task, idx, err.Error())
+			}
+		}
+	}
+	for task, rule := range cfg.ScheduleLimits {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("schedule_limits %s: %s", task, err.Error())
+		}
+	}
+	switch cfg.SeedStrategy {
+	case "", SeedStrategyShared, SeedStrategySupplier, SeedStrategyTask, SeedStrategySession:
+	default:
+		return fmt.Errorf("unknown seed_strategy %q", cfg.SeedStrategy)
+	}
+	return nil
+}
+
+// Sizing of the task buffers. All fields are optional, a zero value means that
+// the value is taken from the "any" entry of the framework or, if not set
+// there either, from the defaults of the task type. The knobs where zero is a
+// valid setting (decay_half_life_days and min_agreement) are pointers, so a
+// task can set them back to zero over the "any" entry.
+type TaskBufferConfig struct {
+	// Length of the circular buffer, this sets the maximum accuracy of the metric
+	CircularBufferLength uint32 `json:"circular_buffer_length"`
+	// Minimum number of samples to consider that the task does not require more samples
+	MinSamplesPerTask uint32 `json:"min_samples_per_task"`
+	// Maximum number of samples to ask at the same time for the task
+	MaxConcurrentSamplesPerTask uint32 `json:"max_concurrent_samples_per_task"`
+	// Maximum age of a sample living in the buffer
+	SampleTTLDays uint32 `json:"sample_ttl_days"`
+	// Half-life of the exponential time-decay used to weight the samples when
+	// calculating the weighted metrics. Zero disables the decay.
+	DecayHalfLifeDays *float64 `json:"decay_half_life_days"`
+	// How the signature of signature tasks is selected from the buffer, "last"
+	// (the last sample) or "majority" (the most frequent in the buffer)
+	ConsensusMode string `json:"consensus_mode"`
+	// Fraction of the buffer samples that must agree with the signature for the
+	// task to be considered OK, zero disables the check
+	MinAgreement *float64 `json:"min_agreement"`
+	// Classes of the sample status codes, see ErrorCodesConfig
+	ErrorCodes *ErrorCodesConfig `json:"error_codes"`
+}
+
+// Classes of the non-zero status codes of the samples. Punishable codes count
+// against the supplier (error rate), neutral codes are kept in the buffer but
+// only count against the supplier availability, and ignored codes are dropped.
+// Codes not listed are ignored.
+type ErrorCodesConfig struct {
+	Punishable []int `json:"punishable"`
+	Neutral    []int `json:"neutral"`
+	Ignored    []int `json:"ignored"`
+}
+
+// Returns a copy of the buffer config where all the unset fields are replaced
+// by the values of the given fallback config.
+func (cfg TaskBufferConfig) WithFallback(fallback TaskBufferConfig) TaskBufferConfig {
+	if cfg.CircularBufferLength == 0 {
+		cfg.CircularBufferLength = fallback.CircularBufferLength
+	}
+	if cfg.MinSamplesPerTask == 0 {
+		cfg.MinSamplesPerTask = fallback.MinSamplesPerTask
+	}
+	if cfg.MaxConcurrentSamplesPerTask == 0 {
+		cfg.MaxConcurrentSamplesPerTask = fallback.MaxConcurrentSamplesPerTask
+	}
+	if cfg.SampleTTLDays == 0 {
+		cfg.SampleTTLDays = fallback.SampleTTLDays
+	}
+	if cfg.DecayHalfLifeDays == nil {
+		cfg.DecayHalfLifeDays = fallback.DecayHalfLifeDays
+	}
+	if cfg.ConsensusMode == "" {
+		cfg.ConsensusMode = fallback.ConsensusMode
+	}
+	if cfg.MinAgreement == nil {
+		cfg.MinAgreement = fallback.MinAgreement
+	}
+	if cfg.ErrorCodes == nil {
+		cfg.ErrorCodes = fallback.ErrorCodes
+	}
+	return cfg
+}
+
+// Returns the decay half-life, zero if not set
+func (cfg TaskBufferConfig) GetDecayHalfLifeDays() float64 {
+	if cfg.DecayHalfLifeDays == nil {
+		return 0
+	}
+	return *cfg.DecayHalfLifeDays
+}
+
+// Returns the minimum signature agreement, zero if not set
+func (cfg TaskBufferConfig) GetMinAgreement() float64 {
+	if cfg.MinAgreement == nil {
+		return 0
+	}
+	return *cfg.MinAgreement
 }
 
 type DevelopConfig struct {
@@ -7,16 +7,21 @@ import (
 )
 
 var (
-	TaskCollection              = "tasks"
-	InstanceCollection          = "instances"
-	PromptsCollection           = "prompts"
-	ResponsesCollection         = "responses"
-	SuppliersCollection         = "suppliers"
-	ResultsCollection           = "results"
-	NumericalTaskCollection     = "buffers_numerical"
-	SignaturesTaskCollection    = "buffers_signatures"
-	TaxonomySummariesCollection = "taxonomy_summaries"
-	TackedTaskSamplesCollection = "tracked_task_samples"
+	TaskCollection                  = "tasks"
+	InstanceCollection              = "instances"
+	PromptsCollection               = "prompts"
+	ResponsesCollection             = "responses"
+	SuppliersCollection             = "suppliers"
+	ResultsCollection               = "results"
+	NumericalTaskCollection         = "buffers_numerical"
+	SignaturesTaskCollection        = "buffers_signatures"
+	DistributionTaskCollection      = "buffers_distribution"
+	TaxonomySummariesCollection     = "taxonomy_summaries"
+	TackedTaskSamplesCollection     = "tracked_task_samples"
+	SupplierEventsCollection        = "supplier_events"
+	SupplierAvailabilityCollection  = "supplier_availability"
+	SupplierDuplicatesCollection    = "supplier_duplicates"
+	SupplierBackendGroupsCollection = "supplier_backend_groups"
 )
 
 type RelayResponse struct {
@@ -0,0 +1,602 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// ------------------------------------------------------------------------------
+// Dependency Rules
+// ------------------------------------------------------------------------------
+
+// Status values of a task dependency rule
+const (
+	DependencyStatusPresent string = "present"
+	DependencyStatusOK      string = "ok"
+	DependencyStatusEqual   string = "equal"
+)
+
+// Comparison operators of a metric dependency rule
+const (
+	RuleOpEq  string = "eq"
+	RuleOpNe  string = "ne"
+	RuleOpGt  string = "gt"
+	RuleOpGte string = "gte"
+	RuleOpLt  string = "lt"
+	RuleOpLte string = "lte"
+)
+
+// Node used in taxonomy rules when none is given
+const DefaultTaxonomyNode string = "root_c"
+
+// A dependency rule is one of:
+//   - An empty rule (`{}` or `"none"`), that is always met.
+//   - A group, with a list of rules that must all (`all`) or any (`any`) be met.
+//   - A task rule, on the buffer of a framework-task pair, checking a `status`
+//     ("present", "ok" or "equal" to `value`) or comparing a `metric` with
+//     `op` against `value`.
+//   - A taxonomy rule, comparing a `metric` of a taxonomy summary `node`
+//     (default "root_c") with `op` against `value`.
+//
+// Examples:
+//
+//	{"framework": "signatures", "task": "tokenizer", "status": "ok"}
+//	{"framework": "lmeh", "task": "mmlu", "metric": "score_lower_bound", "op": "gte", "value": 0.6}
+//	{"any": [{"taxonomy": "liveness_v0", "metric": "score", "op": "gte", "value": 0.8}, {...}]}
+//
+// The legacy colon-separated strings are also accepted and converted on load.
+type DependencyRule struct {
+	All []DependencyRule `json:"all,omitempty"`
+	Any []DependencyRule `json:"any,omitempty"`
+
+	Framework string `json:"framework,omitempty"`
+	Task      string `json:"task,omitempty"`
+	Status    string `json:"status,omitempty"`
+
+	Taxonomy string `json:"taxonomy,omitempty"`
+	Node     string `json:"node,omitempty"`
+
+	Metric string    `json:"metric,omitempty"`
+	Op     string    `json:"op,omitempty"`
+	Value  RuleValue `json:"value,omitempty"`
+}
+
+// Returns true if the rule has no conditions
+func (rule DependencyRule) IsEmpty() bool {
+	return !rule.IsGroup() && !rule.IsTask() && !rule.IsTaxonomy()
+}
+
+// Returns true if the rule is an "all" or "any" group
+func (rule DependencyRule) IsGroup() bool {
+	return len(rule.All) > 0 || len(rule.Any) > 0
+}
+
+// Returns true if the rule checks a task buffer
+func (rule DependencyRule) IsTask() bool {
+	return rule.Framework != "" || rule.Task != ""
+}
+
+// Returns true if the rule checks a taxonomy summary
+func (rule DependencyRule) IsTaxonomy() bool {
+	return rule.Taxonomy != ""
+}
+
+// Returns the taxonomy node checked by the rule
+func (rule DependencyRule) GetNode() string {
+	if rule.Node == "" {
+		return DefaultTaxonomyNode
+	}
+	return rule.Node
+}
+
+// Checks the structure of the rule (and its children). The metric names are
+// not checked here, as they depend on the task types.
+func (rule DependencyRule) Validate() error {
+	kinds := 0
+	for _, isKind := range []bool{rule.IsGroup(), rule.IsTask(), rule.IsTaxonomy()} {
+		if isKind {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("a rule can only be a group (all/any), a task rule (framework/task) or a taxonomy rule (taxonomy)")
+	}
+
+	switch {
+	case rule.IsGroup():
+		if len(rule.All) > 0 && len(rule.Any) > 0 {
+			return fmt.Errorf("a group cannot have both \"all\" and \"any\"")
+		}
+		if rule.Status != "" || rule.Metric != "" || rule.Op != "" || !rule.Value.IsEmpty() {
+			return fmt.Errorf("a group cannot have status, metric, op or value")
+		}
+		children := rule.All
+		if len(rule.Any) > 0 {
+			children = rule.Any
+		}
+		for idx, child := range children {
+			if err := child.Validate(); err != nil {
+				return fmt.Errorf("rule %d of group: %s", idx, err.Error())
+			}
+		}
+		return nil
+
+	case rule.IsTask():
+		if rule.Framework == "" || rule.Task == "" {
+			return fmt.Errorf("a task rule requires both framework and task")
+		}
+		if rule.Node != "" {
+			return fmt.Errorf("a task rule cannot have node")
+		}
+		if rule.Status != "" && rule.Metric != "" {
+			return fmt.Errorf("a task rule can have status or metric, not both")
+		}
+		if rule.Metric != "" {
+			return rule.validateComparison()
+		}
+		switch rule.Status {
+		case DependencyStatusPresent, DependencyStatusOK:
+			if rule.Op != "" || !rule.Value.IsEmpty() {
+				return fmt.Errorf("status %q does not use op or value", rule.Status)
+			}
+		case DependencyStatusEqual:
+			if rule.Op != "" {
+				return fmt.Errorf("status %q does not use op", rule.Status)
+			}
+			if rule.Value.IsEmpty() {
+				return fmt.Errorf("status %q requires a value", rule.Status)
+			}
+			if rule.Value.IsNumber {
+				return fmt.Errorf("status %q requires a text value", rule.Status)
+			}
+		case "":
+			return fmt.Errorf("a task rule requires status or metric")
+		default:
+			return fmt.Errorf("unknown status %q (expected %q, %q or %q)",
+				rule.Status, DependencyStatusPresent, DependencyStatusOK, DependencyStatusEqual)
+		}
+		return nil
+
+	case rule.IsTaxonomy():
+		if rule.Status != "" {
+			return fmt.Errorf("a taxonomy rule cannot have status")
+		}
+		if rule.Metric == "" {
+			return fmt.Errorf("a taxonomy rule requires a metric")
+		}
+		return rule.validateComparison()
+	}
+
+	// Empty rule
+	if rule.Status != "" || rule.Metric != "" || rule.Op != "" || !rule.Value.IsEmpty() || rule.Node != "" {
+		return fmt.Errorf("rule has conditions but no framework/task, taxonomy or group")
+	}
+	return nil
+}
+
+func (rule DependencyRule) validateComparison() error {
+	if !IsValidRuleOp(rule.Op) {
+		return fmt.Errorf("metric %s: unknown op %q (expected one of eq, ne, gt, gte, lt, lte)", rule.Metric, rule.Op)
+	}
+	if !rule.Value.IsNumber {
+		return fmt.Errorf("metric %s: value must be a number", rule.Metric)
+	}
+	return nil
+}
+
+// Returns true if the operator is known
+func IsValidRuleOp(op string) bool {
+	switch op {
+	case RuleOpEq, RuleOpNe, RuleOpGt, RuleOpGte, RuleOpLt, RuleOpLte:
+		return true
+	}
+	return false
+}
+
+// Compares a value against a threshold using the given operator
+func CompareRuleValue(value float64, op string, threshold float64) (bool, error) {
+	switch op {
+	case RuleOpEq:
+		return value == threshold, nil
+	case RuleOpNe:
+		return value != threshold, nil
+	case RuleOpGt:
+		return value > threshold, nil
+	case RuleOpGte:
+		return value >= threshold, nil
+	case RuleOpLt:
+		return value < threshold, nil
+	case RuleOpLte:
+		return value <= threshold, nil
+	}
+	return false, fmt.Errorf("unknown op %q", op)
+}
+
+func (rule *DependencyRule) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var legacy string
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		parsed, err := parseLegacyTaskDependency(legacy)
+		if err != nil {
+			return err
+		}
+		*rule = parsed
+		return nil
+	}
+
+	// Use an alias to avoid calling this method again
+	type dependencyRuleAlias DependencyRule
+	var aux dependencyRuleAlias
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&aux); err != nil {
+		return err
+	}
+	*rule = DependencyRule(aux)
+	return nil
+}
+
+// Converts a legacy task dependency, "framework:task:status:value", into a rule.
+func parseLegacyTaskDependency(legacy string) (DependencyRule, error) {
+	fields := strings.Split(legacy, ":")
+	if len(fields) == 1 && fields[0] == "none" {
+		return DependencyRule{}, nil
+	}
+	if len(fields) != 4 {
+		return DependencyRule{}, fmt.Errorf("malformed dependency %q, expected four elements separated by \":\"", legacy)
+	}
+	if fields[0] == "none" {
+		return DependencyRule{}, nil
+	}
+
+	rule := DependencyRule{Framework: fields[0], Task: fields[1]}
+	switch fields[2] {
+	case DependencyStatusPresent, DependencyStatusOK:
+		rule.Status = fields[2]
+	case DependencyStatusEqual:
+		rule.Status = fields[2]
+		rule.Value = RuleValue{Text: fields[3]}
+	case "score", "score_lb":
+		threshold, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return DependencyRule{}, fmt.Errorf("malformed dependency %q, cannot convert score minimum to float", legacy)
+		}
+		rule.Metric = "mean_score"
+		if fields[2] == "score_lb" {
+			rule.Metric = "score_lower_bound"
+		}
+		rule.Op = RuleOpGte
+		rule.Value = RuleValue{Number: threshold, IsNumber: true}
+	default:
+		return DependencyRule{}, fmt.Errorf("malformed dependency %q, unknown status %q", legacy, fields[2])
+	}
+	return rule, nil
+}
+
+// A list of taxonomy dependency rules. It is only different from a list of
+// rules in how the legacy strings are read, which for taxonomies are
+// "taxonomy:score_min:success_rate_min:samples_min".
+type TaxonomyDependencyRules []DependencyRule
+
+func (rules *TaxonomyDependencyRules) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parsed := make(TaxonomyDependencyRules, 0, len(raw))
+	for idx, entry := range raw {
+		entry = bytes.TrimSpace(entry)
+		if len(entry) > 0 && entry[0] == '"' {
+			var legacy string
+			if err := json.Unmarshal(entry, &legacy); err != nil {
+				return err
+			}
+			rule, err := parseLegacyTaxonomyDependency(legacy)
+			if err != nil {
+				return fmt.Errorf("entry %d: %s", idx, err.Error())
+			}
+			parsed = append(parsed, rule)
+			continue
+		}
+		var rule DependencyRule
+		if err := json.Unmarshal(entry, &rule); err != nil {
+			return fmt.Errorf("entry %d: %s", idx, err.Error())
+		}
+		parsed = append(parsed, rule)
+	}
+	*rules = parsed
+	return nil
+}
+
+// Converts a legacy taxonomy dependency into a rule. The success rate can be
+// skipped using "none".
+func parseLegacyTaxonomyDependency(legacy string) (DependencyRule, error) {
+	fields := strings.Split(legacy, ":")
+	if len(fields) != 4 {
+		return DependencyRule{}, fmt.Errorf("malformed taxonomy dependency %q, expected four elements separated by \":\"", legacy)
+	}
+	if fields[0] == "none" {
+		return DependencyRule{}, nil
+	}
+
+	names := []string{"score", "success_rate", "sample_min"}
+	rule := DependencyRule{}
+	for idx, name := range names {
+		if name == "success_rate" && fields[idx+1] == "none" {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(fields[idx+1], 64)
+		if err != nil {
+			return DependencyRule{}, fmt.Errorf("malformed taxonomy dependency %q, cannot convert %s minimum to float", legacy, name)
+		}
+		rule.All = append(rule.All, DependencyRule{
+			Taxonomy: fields[0],
+			Metric:   name,
+			Op:       RuleOpGte,
+			Value:    RuleValue{Number: threshold, IsNumber: true},
+		})
+	}
+	return rule, nil
+}
+
+// Value of a rule, either a number (used in comparisons) or a string (used in
+// equality checks of tasks that are not numerical, like signatures).
+type RuleValue struct {
+	Number   float64
+	Text     string
+	IsNumber bool
+}
+
+// Returns true if no value was given
+func (value RuleValue) IsEmpty() bool {
+	return !value.IsNumber && value.Text == ""
+}
+
+func (value RuleValue) MarshalJSON() ([]byte, error) {
+	if value.IsNumber {
+		return json.Marshal(value.Number)
+	}
+	return json.Marshal(value.Text)
+}
+
+func (value *RuleValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		*value = RuleValue{}
+		return json.Unmarshal(data, &value.Text)
+	}
+	var number float64
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("rule value must be a number or a string")
+	}
+	*value = RuleValue{Number: number, IsNumber: true}
+	return nil
+}
+
+// ------------------------------------------------------------------------------
+// Schedule Rules
+// ------------------------------------------------------------------------------
+
+// Units of a schedule rule
+const (
+	ScheduleUnitNone    string = "none"
+	ScheduleUnitSession string = "session"
+	ScheduleUnitBlock   string = "block"
+	ScheduleUnitHours   string = "hours"
+	ScheduleUnitMinutes string = "minutes"
+)
+
+// Limits on when a task can be triggered. All the given limits must be met:
+//   - A minimum interval since the last sample, for example
+//     `{"every": 1, "unit": "session"}`.
+//   - A cron expression (standard five fields, UTC), the task can be triggered
+//     once a scheduled time has passed since the last sample, for example
+//     `{"cron": "0 */6 * * *"}`.
+//   - A time window (UTC), the task can only be triggered inside it, for
+//     example `{"window": {"start": "00:00", "end": "06:00", "days": ["weekdays"]}}`.
+//
+// The legacy "every:unit" strings are also accepted, "none:none" (or
+// `{"unit": "none"}`) disables the limits.
+type ScheduleRule struct {
+	Every  int64           `json:"every"`
+	Unit   string          `json:"unit"`
+	Cron   string          `json:"cron,omitempty"`
+	Window *ScheduleWindow `json:"window,omitempty"`
+}
+
+// Returns true if the rule does not limit the schedule
+func (rule ScheduleRule) IsNone() bool {
+	return (rule.Unit == ScheduleUnitNone || rule.Unit == "") && rule.Cron == "" && rule.Window == nil
+}
+
+// Returns true if the rule sets a minimum interval between triggers
+func (rule ScheduleRule) HasInterval() bool {
+	return rule.Unit != ScheduleUnitNone && rule.Unit != ""
+}
+
+// Checks the rule values
+func (rule ScheduleRule) Validate() error {
+	if rule.Unit == "" && rule.Cron == "" && rule.Window == nil {
+		return fmt.Errorf("schedule requires a unit, a cron expression or a window (use \"none:none\" for no limits)")
+	}
+	if rule.Cron != "" {
+		if _, err := cron.ParseStandard(rule.Cron); err != nil {
+			return fmt.Errorf("invalid schedule cron %q: %s", rule.Cron, err.Error())
+		}
+	}
+	if rule.Window != nil {
+		if err := rule.Window.Validate(); err != nil {
+			return err
+		}
+	}
+	switch rule.Unit {
+	case ScheduleUnitNone, "":
+		if rule.Every != 0 {
+			return fmt.Errorf("schedule every requires a unit (session, block, hours or minutes)")
+		}
+		return nil
+	case ScheduleUnitSession, ScheduleUnitBlock, ScheduleUnitHours, ScheduleUnitMinutes:
+		if rule.Every <= 0 {
+			return fmt.Errorf("schedule every must be a positive integer")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown schedule unit %q (expected none, session, block, hours or minutes)", rule.Unit)
+}
+
+// Returns true if the cron expression of the rule has a scheduled time between
+// the last trigger and now. Rules without cron expression are always due.
+func (rule ScheduleRule) IsCronDue(lastSeen time.Time, now time.Time) (bool, error) {
+	if rule.Cron == "" {
+		return true, nil
+	}
+	schedule, err := cron.ParseStandard(rule.Cron)
+	if err != nil {
+		return false, err
+	}
+	return !schedule.Next(lastSeen.UTC()).After(now.UTC()), nil
+}
+
+// Returns true if the given time is inside the time window of the rule. Rules
+// without window always contain it.
+func (rule ScheduleRule) InWindow(now time.Time) bool {
+	if rule.Window == nil {
+		return true
+	}
+	return rule.Window.Contains(now)
+}
+
+// Day names accepted in schedule windows, plus "weekdays" and "weekends"
+var scheduleDays = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// A daily time window in UTC. If start is after end the window wraps around
+// midnight (i.e. "22:00" to "04:00"). If days are given, the window is only
+// open on those days (of the current UTC time). Both start and end are
+// optional, defaulting to the whole day.
+type ScheduleWindow struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days"`
+}
+
+// Checks the window values
+func (window ScheduleWindow) Validate() error {
+	start, err := parseClock(window.Start, 0)
+	if err != nil {
+		return fmt.Errorf("invalid schedule window start: %s", err.Error())
+	}
+	end, err := parseClock(window.End, 24*60)
+	if err != nil {
+		return fmt.Errorf("invalid schedule window end: %s", err.Error())
+	}
+	if start == end {
+		// It would never be open
+		return fmt.Errorf("schedule window start and end cannot be the same time")
+	}
+	for _, day := range window.Days {
+		if _, ok := scheduleDays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid schedule window day %q (expected mon, tue, wed, thu, fri, sat, sun, weekdays or weekends)", day)
+		}
+	}
+	return nil
+}
+
+// Returns true if the given time is inside the window
+func (window ScheduleWindow) Contains(now time.Time) bool {
+	now = now.UTC()
+
+	if len(window.Days) > 0 {
+		dayOK := false
+		for _, day := range window.Days {
+			for _, weekday := range scheduleDays[strings.ToLower(day)] {
+				if now.Weekday() == weekday {
+					dayOK = true
+				}
+			}
+		}
+		if !dayOK {
+			return false
+		}
+	}
+
+	// Invalid values are rejected when validating the config
+	start, _ := parseClock(window.Start, 0)
+	end, _ := parseClock(window.End, 24*60)
+	minute := now.Hour()*60 + now.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	// Wraps around midnight
+	return minute >= start || minute < end
+}
+
+// Parses a "HH:MM" time into minutes since midnight, returning the default
+// value for empty strings. "24:00" is accepted as the end of the day.
+func parseClock(clock string, defaultMinutes int) (int, error) {
+	if clock == "" {
+		return defaultMinutes, nil
+	}
+	fields := strings.Split(clock, ":")
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("time %q must be in HH:MM format", clock)
+	}
+	hours, errH := strconv.Atoi(fields[0])
+	minutes, errM := strconv.Atoi(fields[1])
+	if errH != nil || errM != nil || hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("time %q must be in HH:MM format", clock)
+	}
+	return hours*60 + minutes, nil
+}
+
+func (rule *ScheduleRule) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var legacy string
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		fields := strings.Split(legacy, ":")
+		if len(fields) != 2 {
+			return fmt.Errorf("malformed schedule %q, expected two elements separated by \":\"", legacy)
+		}
+		if fields[0] == "none" {
+			*rule = ScheduleRule{Unit: ScheduleUnitNone}
+			return nil
+		}
+		every, err := strconv.ParseInt(fields[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("malformed schedule %q, first element must be an integer number", legacy)
+		}
+		*rule = ScheduleRule{Every: every, Unit: fields[1]}
+		return nil
+	}
+
+	type scheduleRuleAlias ScheduleRule
+	var aux scheduleRuleAlias
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&aux); err != nil {
+		return err
+	}
+	*rule = ScheduleRule(aux)
+	return nil
+}
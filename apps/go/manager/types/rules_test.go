@@ -0,0 +1,261 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func numberValue(number float64) RuleValue {
+	return RuleValue{Number: number, IsNumber: true}
+}
+
+func TestDependencyRuleUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    DependencyRule
+		wantErr bool
+	}{
+		{name: "legacy none", input: `"none"`, want: DependencyRule{}},
+		{name: "legacy none with fields", input: `"none:none:none:none"`, want: DependencyRule{}},
+		{
+			name:  "legacy status",
+			input: `"signatures:tokenizer:ok:none"`,
+			want:  DependencyRule{Framework: "signatures", Task: "tokenizer", Status: DependencyStatusOK},
+		},
+		{
+			name:  "legacy equal",
+			input: `"signatures:tokenizer:equal:abc"`,
+			want:  DependencyRule{Framework: "signatures", Task: "tokenizer", Status: DependencyStatusEqual, Value: RuleValue{Text: "abc"}},
+		},
+		{
+			name:  "legacy score",
+			input: `"lmeh:mmlu:score:0.5"`,
+			want:  DependencyRule{Framework: "lmeh", Task: "mmlu", Metric: "mean_score", Op: RuleOpGte, Value: numberValue(0.5)},
+		},
+		{
+			name:  "legacy score lower bound",
+			input: `"lmeh:mmlu:score_lb:0.5"`,
+			want:  DependencyRule{Framework: "lmeh", Task: "mmlu", Metric: "score_lower_bound", Op: RuleOpGte, Value: numberValue(0.5)},
+		},
+		{name: "legacy score not a number", input: `"lmeh:mmlu:score:high"`, wantErr: true},
+		{name: "legacy missing fields", input: `"lmeh:mmlu"`, wantErr: true},
+		{name: "legacy unknown status", input: `"lmeh:mmlu:done:1"`, wantErr: true},
+		{
+			name:  "metric rule",
+			input: `{"framework": "lmeh", "task": "mmlu", "metric": "mean_score", "op": "gte", "value": 0.6}`,
+			want:  DependencyRule{Framework: "lmeh", Task: "mmlu", Metric: "mean_score", Op: RuleOpGte, Value: numberValue(0.6)},
+		},
+		{
+			name:  "group with legacy children",
+			input: `{"any": ["signatures:tokenizer:ok:none", {"taxonomy": "liveness", "node": "root", "metric": "score", "op": "gt", "value": 0.8}]}`,
+			want: DependencyRule{Any: []DependencyRule{
+				{Framework: "signatures", Task: "tokenizer", Status: DependencyStatusOK},
+				{Taxonomy: "liveness", Node: "root", Metric: "score", Op: RuleOpGt, Value: numberValue(0.8)},
+			}},
+		},
+		{name: "unknown field", input: `{"frameworks": "lmeh"}`, wantErr: true},
+		{name: "invalid value", input: `{"framework": "lmeh", "task": "mmlu", "status": "equal", "value": true}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule DependencyRule
+			err := json.Unmarshal([]byte(tt.input), &rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rule, tt.want) {
+				t.Errorf("got %+v, want %+v", rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaxonomyDependencyRulesUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    TaxonomyDependencyRules
+		wantErr bool
+	}{
+		{name: "legacy none", input: `["none:none:none:none"]`, want: TaxonomyDependencyRules{{}}},
+		{
+			name:  "legacy without success rate",
+			input: `["liveness:0.8:none:5"]`,
+			want: TaxonomyDependencyRules{{All: []DependencyRule{
+				{Taxonomy: "liveness", Metric: "score", Op: RuleOpGte, Value: numberValue(0.8)},
+				{Taxonomy: "liveness", Metric: "sample_min", Op: RuleOpGte, Value: numberValue(5)},
+			}}},
+		},
+		{
+			name:  "legacy with success rate and a rule",
+			input: `["liveness:0.8:0.9:5", {"taxonomy": "babi", "metric": "error_rate", "op": "lt", "value": 0.1}]`,
+			want: TaxonomyDependencyRules{
+				{All: []DependencyRule{
+					{Taxonomy: "liveness", Metric: "score", Op: RuleOpGte, Value: numberValue(0.8)},
+					{Taxonomy: "liveness", Metric: "success_rate", Op: RuleOpGte, Value: numberValue(0.9)},
+					{Taxonomy: "liveness", Metric: "sample_min", Op: RuleOpGte, Value: numberValue(5)},
+				}},
+				{Taxonomy: "babi", Metric: "error_rate", Op: RuleOpLt, Value: numberValue(0.1)},
+			},
+		},
+		{name: "legacy score not a number", input: `["liveness:high:none:5"]`, wantErr: true},
+		{name: "legacy missing fields", input: `["liveness:0.8"]`, wantErr: true},
+		{name: "not a list", input: `"liveness:0.8:none:5"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules TaxonomyDependencyRules
+			err := json.Unmarshal([]byte(tt.input), &rules)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rules, tt.want) {
+				t.Errorf("got %+v, want %+v", rules, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencyRuleValidate(t *testing.T) {
+	taskRule := DependencyRule{Framework: "signatures", Task: "tokenizer", Status: DependencyStatusOK}
+
+	tests := []struct {
+		name    string
+		rule    DependencyRule
+		wantErr bool
+	}{
+		{name: "empty", rule: DependencyRule{}},
+		{name: "status", rule: taskRule},
+		{name: "equal", rule: DependencyRule{Framework: "signatures", Task: "tokenizer", Status: DependencyStatusEqual, Value: RuleValue{Text: "abc"}}},
+		{name: "task metric", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Metric: "mean_score", Op: RuleOpGte, Value: numberValue(0.5)}},
+		{name: "taxonomy metric", rule: DependencyRule{Taxonomy: "liveness", Metric: "score", Op: RuleOpGte, Value: numberValue(0.5)}},
+		{name: "group", rule: DependencyRule{All: []DependencyRule{taskRule, {Taxonomy: "liveness", Metric: "score", Op: RuleOpLt, Value: numberValue(1)}}}},
+
+		{name: "task and taxonomy", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Taxonomy: "liveness", Status: DependencyStatusOK}, wantErr: true},
+		{name: "group with all and any", rule: DependencyRule{All: []DependencyRule{taskRule}, Any: []DependencyRule{taskRule}}, wantErr: true},
+		{name: "group with metric", rule: DependencyRule{All: []DependencyRule{taskRule}, Metric: "score"}, wantErr: true},
+		{name: "invalid child", rule: DependencyRule{Any: []DependencyRule{{Framework: "lmeh"}}}, wantErr: true},
+		{name: "task without framework", rule: DependencyRule{Task: "mmlu", Status: DependencyStatusOK}, wantErr: true},
+		{name: "task with node", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Node: "root", Status: DependencyStatusOK}, wantErr: true},
+		{name: "status and metric", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Status: DependencyStatusOK, Metric: "mean_score", Op: RuleOpGte, Value: numberValue(0.5)}, wantErr: true},
+		{name: "status with value", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Status: DependencyStatusPresent, Value: numberValue(1)}, wantErr: true},
+		{name: "equal without value", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Status: DependencyStatusEqual}, wantErr: true},
+		{name: "equal with op", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Status: DependencyStatusEqual, Op: RuleOpEq, Value: numberValue(1)}, wantErr: true},
+		{name: "equal with number", rule: DependencyRule{Framework: "signatures", Task: "tokenizer", Status: DependencyStatusEqual, Value: numberValue(1)}, wantErr: true},
+		{name: "unknown status", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Status: "done"}, wantErr: true},
+		{name: "task without condition", rule: DependencyRule{Framework: "lmeh", Task: "mmlu"}, wantErr: true},
+		{name: "unknown op", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Metric: "mean_score", Op: ">=", Value: numberValue(0.5)}, wantErr: true},
+		{name: "text value in comparison", rule: DependencyRule{Framework: "lmeh", Task: "mmlu", Metric: "mean_score", Op: RuleOpGte, Value: RuleValue{Text: "0.5"}}, wantErr: true},
+		{name: "taxonomy with status", rule: DependencyRule{Taxonomy: "liveness", Status: DependencyStatusOK}, wantErr: true},
+		{name: "taxonomy without metric", rule: DependencyRule{Taxonomy: "liveness", Op: RuleOpGte, Value: numberValue(0.5)}, wantErr: true},
+		{name: "conditions without target", rule: DependencyRule{Metric: "score", Op: RuleOpGte, Value: numberValue(0.5)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestScheduleRuleUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ScheduleRule
+		wantErr bool
+	}{
+		{name: "legacy none", input: `"none:none"`, want: ScheduleRule{Unit: ScheduleUnitNone}},
+		{name: "legacy interval", input: `"2:session"`, want: ScheduleRule{Every: 2, Unit: ScheduleUnitSession}},
+		{name: "legacy every not a number", input: `"one:session"`, wantErr: true},
+		{name: "legacy missing unit", input: `"2"`, wantErr: true},
+		{
+			name:  "cron and window",
+			input: `{"cron": "0 */6 * * *", "window": {"start": "22:00", "end": "04:00", "days": ["weekdays"]}}`,
+			want:  ScheduleRule{Cron: "0 */6 * * *", Window: &ScheduleWindow{Start: "22:00", End: "04:00", Days: []string{"weekdays"}}},
+		},
+		{name: "unknown field", input: `{"every": 1, "units": "hours"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule ScheduleRule
+			err := json.Unmarshal([]byte(tt.input), &rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rule, tt.want) {
+				t.Errorf("got %+v, want %+v", rule, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleRuleValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    ScheduleRule
+		wantErr bool
+	}{
+		{name: "none", rule: ScheduleRule{Unit: ScheduleUnitNone}},
+		{name: "interval", rule: ScheduleRule{Every: 2, Unit: ScheduleUnitHours}},
+		{name: "cron only", rule: ScheduleRule{Cron: "0 */6 * * *"}},
+		{name: "whole day window", rule: ScheduleRule{Window: &ScheduleWindow{}}},
+		{name: "window until midnight", rule: ScheduleRule{Window: &ScheduleWindow{Start: "00:00", End: "24:00"}}},
+		{name: "window wrapping midnight", rule: ScheduleRule{Window: &ScheduleWindow{Start: "22:00", End: "04:00", Days: []string{"Sat", "weekends"}}}},
+
+		{name: "empty", rule: ScheduleRule{}, wantErr: true},
+		{name: "interval without every", rule: ScheduleRule{Unit: ScheduleUnitSession}, wantErr: true},
+		{name: "negative every", rule: ScheduleRule{Every: -1, Unit: ScheduleUnitBlock}, wantErr: true},
+		{name: "every with unit none", rule: ScheduleRule{Every: 2, Unit: ScheduleUnitNone}, wantErr: true},
+		{name: "every without unit", rule: ScheduleRule{Every: 2, Cron: "0 * * * *"}, wantErr: true},
+		{name: "unknown unit", rule: ScheduleRule{Every: 2, Unit: "days"}, wantErr: true},
+		{name: "invalid cron", rule: ScheduleRule{Cron: "every day"}, wantErr: true},
+		{name: "window start equal to end", rule: ScheduleRule{Window: &ScheduleWindow{Start: "06:00", End: "06:00"}}, wantErr: true},
+		{name: "window of zero length by default", rule: ScheduleRule{Window: &ScheduleWindow{End: "00:00"}}, wantErr: true},
+		{name: "window invalid hour", rule: ScheduleRule{Window: &ScheduleWindow{Start: "25:00"}}, wantErr: true},
+		{name: "window invalid minutes", rule: ScheduleRule{Window: &ScheduleWindow{End: "10:60"}}, wantErr: true},
+		{name: "window invalid format", rule: ScheduleRule{Window: &ScheduleWindow{Start: "6am"}}, wantErr: true},
+		{name: "window unknown day", rule: ScheduleRule{Window: &ScheduleWindow{Days: []string{"monday"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
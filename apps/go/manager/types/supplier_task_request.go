@@ -23,10 +23,17 @@ type TaskRequestRecord struct {
 	Task           string             `bson:"tasks"`
 	Blacklist      []int              `bson:"blacklist"`
 	Qty            int                `bson:"qty"`
+	RandomSeed     int                `bson:"random_seed"`
 	TotalInstances int                `bson:"total_instances"`
 	RequestType    string             `bson:"request_type"`
 	Done           bool               `bson:"done"`
 	Drop           bool               `bson:"drop"`
+	// Number of times the evaluation of this task was retried
+	EvaluationRetries uint32 `bson:"evaluation_retries"`
+	// Steps already done when recording the task as stale (see
+	// StaleTaskReaper)
+	StaleBufferRecorded       bool `bson:"stale_buffer_recorded"`
+	StaleAvailabilityRecorded bool `bson:"stale_availability_recorded"`
 }
 
 // ------------------------------------------------------------------------------
@@ -34,5 +41,5 @@ type TaskRequestRecord struct {
 // ------------------------------------------------------------------------------
 type InstanceRecord struct {
 	TaskID primitive.ObjectID `bson:"task_id"`
-	DocIds []int              `bson:"doc_id"`
+	DocID  int                `bson:"doc_id"`
 }
@@ -12,6 +12,7 @@ type TaxonomyNode struct {
 	RunTime    float64 `bson:"run_time"`
 	RunTimeDev float64 `bson:"run_time_dev"`
 	SampleMin  int64   `bson:"sample_min"`
+	ErrorRate  float64 `bson:"error_rate"`
 }
 
 type TaxonomySummary struct {
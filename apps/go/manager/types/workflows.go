@@ -10,6 +10,9 @@ type TestsData struct {
 type SupplierManagerParams struct {
 	Service string      `json:"service"`
 	Tests   []TestsData `json:"tests"`
+	// Fail if a test depends on a task that is not part of the tests, instead
+	// of expecting it to be triggered by other calls to this workflow
+	StrictDependencies bool `json:"strict_dependencies"`
 }
 
 type SupplierManagerResults struct {
@@ -17,6 +20,8 @@ type SupplierManagerResults struct {
 	FailedSuppliers  uint `json:"failed"`
 	NewSuppliers     uint `json:"new_suppliers"`
 	TriggeredTasks   uint `json:"triggered_tasks"`
+	// Samples left for the next run due to the trigger budget
+	DeferredSamples uint `json:"deferred_samples"`
 }
 
 type SupplierAnalysisChanResponse struct {
@@ -40,3 +45,88 @@ type ResultAnalyzerParams struct {
 type ResultAnalyzerResults struct {
 	Success bool `json:"success"`
 }
+
+type DuplicateResponsesParams struct {
+	// Only the tracked samples of the last hours are compared
+	LookbackHours uint `json:"lookback_hours"`
+	// Minimum number of prompts answered by both suppliers to compare them
+	MinSharedDocs uint `json:"min_shared_docs"`
+	// Fraction of identical or near-identical responses to flag a pair
+	MinMatchRate float64 `json:"min_match_rate"`
+	// Shorter responses (once normalized) are not compared, since different
+	// backends can easily give the same short answer
+	MinResponseLength uint `json:"min_response_length"`
+}
+
+type DuplicateResponsesResults struct {
+	Samples       uint `json:"samples"`
+	ComparedPairs uint `json:"compared_pairs"`
+	FlaggedPairs  uint `json:"flagged_pairs"`
+}
+
+type BackendClustersParams struct {
+	// Only the tracked samples of the last hours are used for the responses
+	// formatting features
+	LookbackHours uint `json:"lookback_hours"`
+	// Minimum similarity (in [0, 1]) of two suppliers to link them
+	MinSimilarity float64 `json:"min_similarity"`
+	// Minimum number of features available on both suppliers to link them,
+	// the signatures are not counted
+	MinComponents uint `json:"min_components"`
+}
+
+type BackendClustersResults struct {
+	Suppliers        uint `json:"suppliers"`
+	Groups           uint `json:"groups"`
+	GroupedSuppliers uint `json:"grouped_suppliers"`
+}
+
+type StaleTaskReaperParams struct {
+	// Tasks not done after this many hours are reaped
+	MaxAgeHours uint `json:"max_age_hours"`
+	// Tasks processed by each activity call
+	BatchSize uint `json:"batch_size"`
+	// Maximum activity calls per run, the rest are left for the next run
+	MaxBatches uint `json:"max_batches"`
+}
+
+type StaleTaskReaperResults struct {
+	Reaped         uint `json:"reaped"`
+	TimeoutSamples uint `json:"timeout_samples"`
+	Unrecorded     uint `json:"unrecorded"`
+	Skipped        uint `json:"skipped"`
+}
+
+type OrphanCleanupParams struct {
+	// Documents newer than this are not checked
+	GraceMinutes uint `json:"grace_minutes"`
+	// Documents scanned by each activity call
+	BatchSize uint `json:"batch_size"`
+	// Maximum activity calls per workflow run, when reached the workflow
+	// continues as new from the last scanned document
+	MaxBatches uint `json:"max_batches"`
+	// Only report the orphans, without removing them
+	DryRun bool `json:"dry_run"`
+	// Position of the scan, only set when continuing as new: the collection
+	// being scanned and its last scanned document
+	Collection string             `json:"collection,omitempty"`
+	AfterID    primitive.ObjectID `json:"after_id"`
+}
+
+// Orphans found in one collection
+type OrphanCleanupReport struct {
+	Scanned uint `json:"scanned"`
+	Orphans uint `json:"orphans"`
+	Removed uint `json:"removed"`
+	// Distinct task IDs referenced by the orphans, and a few of them as
+	// example
+	OrphanTasks      uint     `json:"orphan_tasks"`
+	OrphanTaskSample []string `json:"orphan_task_sample"`
+	// The collection was not fully scanned in this run
+	Incomplete bool `json:"incomplete"`
+}
+
+type OrphanCleanupResults struct {
+	DryRun      bool                           `json:"dry_run"`
+	Collections map[string]OrphanCleanupReport `json:"collections"`
+}
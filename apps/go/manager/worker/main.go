@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"manager/activities"
+	"manager/records"
+	"os"
 	"packages/logger"
 
 	"go.temporal.io/sdk/client"
@@ -14,6 +17,21 @@ import (
 
 func main() {
 
+	printDependencyGraph := flag.Bool("print-dependency-graph", false, "print the task dependency graph in DOT format and exit")
+	flag.Parse()
+
+	if *printDependencyGraph {
+		// Only the config is needed, the configuration is validated on load
+		x.LoadConfigFile()
+		graph, ok := records.GetDependencyGraph()
+		if !ok {
+			fmt.Fprintln(os.Stderr, "task dependency graph not validated")
+			os.Exit(1)
+		}
+		fmt.Print(graph.DOT())
+		return
+	}
+
 	// Initialize application things like logger/configs/etc
 	ac := x.Initialize()
 
@@ -0,0 +1,63 @@
+package workflows
+
+import (
+	"time"
+
+	"manager/activities"
+	"manager/records"
+	"manager/types"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+var BackendClustersName = "Manager-BackendClusters"
+
+// BackendClusters - Is a method that groups the suppliers believed to share one
+// backend, using their signatures, latency profiles, error strings, responses
+// formatting and identical responses (see DuplicateResponses).
+func (wCtx *Ctx) BackendClusters(ctx workflow.Context, params types.BackendClustersParams) (*types.BackendClustersResults, error) {
+
+	l := wCtx.App.Logger
+	l.Debug().Msg("Starting Backend Clusters Workflow.")
+
+	// Create result
+	result := types.BackendClustersResults{}
+
+	// Set defaults
+	if params.LookbackHours == 0 {
+		params.LookbackHours = records.FingerprintDefaultLookbackHours
+	}
+	if params.MinSimilarity == 0 {
+		params.MinSimilarity = records.FingerprintDefaultMinSimilarity
+	}
+	if params.MinComponents == 0 {
+		params.MinComponents = records.FingerprintDefaultMinComponents
+	}
+
+	// -------------------------------------------------------------------------
+	// -------------------- Cluster Backends -----------------------------------
+	// -------------------------------------------------------------------------
+	ctxTimeout := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute * 5,
+		StartToCloseTimeout:    time.Minute * 15,
+	})
+	// Set activity input
+	clusterBackendsInput := types.ClusterBackendsParams{
+		Since:         workflow.Now(ctx).UTC().Add(-time.Duration(params.LookbackHours) * time.Hour),
+		MinSimilarity: params.MinSimilarity,
+		MinComponents: params.MinComponents,
+	}
+	// Results will be kept logged by temporal
+	var clusterBackendsData types.ClusterBackendsResults
+	// Execute activity
+	err := workflow.ExecuteActivity(ctxTimeout, activities.ClusterBackendsName, clusterBackendsInput).Get(ctx, &clusterBackendsData)
+	if err != nil {
+		return &result, err
+	}
+
+	result.Suppliers = clusterBackendsData.Suppliers
+	result.Groups = clusterBackendsData.Groups
+	result.GroupedSuppliers = clusterBackendsData.GroupedSuppliers
+
+	return &result, nil
+}
@@ -0,0 +1,72 @@
+package workflows
+
+import (
+	"time"
+
+	"manager/activities"
+	"manager/records"
+	"manager/types"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+var DuplicateResponsesName = "Manager-DuplicateResponses"
+
+// DuplicateResponses - Is a method that compares the tracked responses of all
+// suppliers, looking for suppliers that return identical responses to the same
+// prompts (a shared backend or replayed responses). It requires the
+// `track_successful_samples` option.
+func (wCtx *Ctx) DuplicateResponses(ctx workflow.Context, params types.DuplicateResponsesParams) (*types.DuplicateResponsesResults, error) {
+
+	l := wCtx.App.Logger
+	l.Debug().Msg("Starting Duplicate Responses Workflow.")
+
+	// Create result
+	result := types.DuplicateResponsesResults{}
+
+	if !wCtx.App.Config.TrackSuccessfulSamples {
+		l.Warn().Msg("Successful samples are not tracked, there are no responses to compare.")
+	}
+
+	// Set defaults
+	if params.LookbackHours == 0 {
+		params.LookbackHours = records.DuplicatesDefaultLookbackHours
+	}
+	if params.MinSharedDocs == 0 {
+		params.MinSharedDocs = records.DuplicatesDefaultMinSharedDocs
+	}
+	if params.MinMatchRate == 0 {
+		params.MinMatchRate = records.DuplicatesDefaultMinMatchRate
+	}
+	if params.MinResponseLength == 0 {
+		params.MinResponseLength = records.DuplicatesDefaultMinResponseLength
+	}
+
+	// -------------------------------------------------------------------------
+	// -------------------- Find Duplicates ------------------------------------
+	// -------------------------------------------------------------------------
+	ctxTimeout := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute * 5,
+		StartToCloseTimeout:    time.Minute * 15,
+	})
+	// Set activity input
+	findDuplicatesInput := types.FindDuplicateResponsesParams{
+		Since:             workflow.Now(ctx).UTC().Add(-time.Duration(params.LookbackHours) * time.Hour),
+		MinSharedDocs:     params.MinSharedDocs,
+		MinMatchRate:      params.MinMatchRate,
+		MinResponseLength: params.MinResponseLength,
+	}
+	// Results will be kept logged by temporal
+	var findDuplicatesData types.FindDuplicateResponsesResults
+	// Execute activity
+	err := workflow.ExecuteActivity(ctxTimeout, activities.FindDuplicateResponsesName, findDuplicatesInput).Get(ctx, &findDuplicatesData)
+	if err != nil {
+		return &result, err
+	}
+
+	result.Samples = findDuplicatesData.Samples
+	result.ComparedPairs = findDuplicatesData.ComparedPairs
+	result.FlaggedPairs = findDuplicatesData.FlaggedPairs
+
+	return &result, nil
+}
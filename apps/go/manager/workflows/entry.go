@@ -43,4 +43,24 @@ func (wCtx *Ctx) Register(w worker.Worker) {
 	w.RegisterWorkflowWithOptions(wCtx.ResultAnalyzer, workflow.RegisterOptions{
 		Name: ResultAnalyzerName,
 	})
+
+	// Periodic workflow that looks for suppliers returning identical responses
+	w.RegisterWorkflowWithOptions(wCtx.DuplicateResponses, workflow.RegisterOptions{
+		Name: DuplicateResponsesName,
+	})
+
+	// Periodic workflow that groups the suppliers sharing a backend
+	w.RegisterWorkflowWithOptions(wCtx.BackendClusters, workflow.RegisterOptions{
+		Name: BackendClustersName,
+	})
+
+	// Periodic workflow that closes the tasks that never finished
+	w.RegisterWorkflowWithOptions(wCtx.StaleTaskReaper, workflow.RegisterOptions{
+		Name: StaleTaskReaperName,
+	})
+
+	// Periodic workflow that removes the task tree documents of removed tasks
+	w.RegisterWorkflowWithOptions(wCtx.OrphanCleanup, workflow.RegisterOptions{
+		Name: OrphanCleanupName,
+	})
 }
@@ -0,0 +1,135 @@
+package workflows
+
+import (
+	"time"
+
+	"manager/activities"
+	"manager/records"
+	"manager/types"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.temporal.io/sdk/workflow"
+)
+
+var OrphanCleanupName = "Manager-OrphanCleanup"
+
+// OrphanCleanup - Is a method that removes the documents of the instances,
+// prompts, responses and results collections whose task no longer exists.
+// They are left behind when a task removal is interrupted or when a response
+// arrives after its task was removed. The collections are scanned in batches,
+// after MaxBatches the workflow continues as new from the last scanned
+// document, so the history of a run stays bounded. In dry-run mode the orphans
+// are only reported. The reports of each run only cover the batches of that
+// run.
+func (wCtx *Ctx) OrphanCleanup(ctx workflow.Context, params types.OrphanCleanupParams) (*types.OrphanCleanupResults, error) {
+
+	l := wCtx.App.Logger
+	l.Debug().Bool("dry_run", params.DryRun).Str("collection", params.Collection).Msg("Starting Orphan Cleanup Workflow.")
+
+	// Create result
+	result := types.OrphanCleanupResults{
+		DryRun:      params.DryRun,
+		Collections: make(map[string]types.OrphanCleanupReport),
+	}
+
+	// Set defaults
+	if params.GraceMinutes == 0 {
+		params.GraceMinutes = records.OrphansDefaultGraceMinutes
+	}
+	if params.BatchSize == 0 {
+		params.BatchSize = records.OrphansDefaultBatchSize
+	}
+	if params.MaxBatches == 0 {
+		params.MaxBatches = records.OrphansDefaultMaxBatches
+	}
+
+	// Resume from the collection being scanned, if continuing as new
+	start := 0
+	if params.Collection != "" {
+		for idx, collection := range records.OrphanCollections {
+			if collection.Name == params.Collection {
+				start = idx
+			}
+		}
+	}
+
+	// -------------------------------------------------------------------------
+	// -------------------- Clean Orphans --------------------------------------
+	// -------------------------------------------------------------------------
+	ctxTimeout := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute * 5,
+		StartToCloseTimeout:    time.Minute * 5,
+	})
+	olderThan := workflow.Now(ctx).UTC().Add(-time.Duration(params.GraceMinutes) * time.Minute)
+	batches := uint(0)
+	for idx := start; idx < len(records.OrphanCollections); idx++ {
+		collection := records.OrphanCollections[idx]
+		report := types.OrphanCleanupReport{OrphanTaskSample: make([]string, 0)}
+		orphanTasks := make(map[primitive.ObjectID]bool)
+		// Set activity input
+		cleanOrphansInput := types.CleanOrphansParams{
+			Collection: collection.Name,
+			OlderThan:  olderThan,
+			BatchSize:  params.BatchSize,
+			DryRun:     params.DryRun,
+		}
+		if collection.Name == params.Collection {
+			cleanOrphansInput.AfterID = params.AfterID
+		}
+		for {
+			if batches >= params.MaxBatches {
+				// Batches limit reached, log what was done and continue as
+				// new from here
+				report.Incomplete = true
+				report.OrphanTasks = uint(len(orphanTasks))
+				result.Collections[collection.Name] = report
+				logOrphanCleanupReport(wCtx, collection.Name, report, params.DryRun)
+				params.Collection = collection.Name
+				params.AfterID = cleanOrphansInput.AfterID
+				return &result, workflow.NewContinueAsNewError(ctx, OrphanCleanupName, params)
+			}
+			batches++
+			// Results will be kept logged by temporal
+			var cleanOrphansData types.CleanOrphansResults
+			// Execute activity
+			err := workflow.ExecuteActivity(ctxTimeout, activities.CleanOrphansName, cleanOrphansInput).Get(ctx, &cleanOrphansData)
+			if err != nil {
+				return &result, err
+			}
+			report.Scanned += cleanOrphansData.Scanned
+			report.Orphans += cleanOrphansData.Orphans
+			report.Removed += cleanOrphansData.Removed
+			for _, taskID := range cleanOrphansData.OrphanTaskIDs {
+				if orphanTasks[taskID] {
+					continue
+				}
+				orphanTasks[taskID] = true
+				if len(report.OrphanTaskSample) < records.OrphansReportSampleSize {
+					report.OrphanTaskSample = append(report.OrphanTaskSample, taskID.Hex())
+				}
+			}
+			if !cleanOrphansData.More {
+				break
+			}
+			cleanOrphansInput.AfterID = cleanOrphansData.LastID
+		}
+		report.OrphanTasks = uint(len(orphanTasks))
+		result.Collections[collection.Name] = report
+		logOrphanCleanupReport(wCtx, collection.Name, report, params.DryRun)
+	}
+
+	return &result, nil
+}
+
+func logOrphanCleanupReport(wCtx *Ctx, collection string, report types.OrphanCleanupReport, dryRun bool) {
+	wCtx.App.Logger.Info().
+		Str("collection", collection).
+		Uint("scanned", report.Scanned).
+		Uint("orphans", report.Orphans).
+		Uint("orphan_tasks", report.OrphanTasks).
+		Strs("orphan_task_sample", report.OrphanTaskSample).
+		Uint("removed", report.Removed).
+		Bool("incomplete", report.Incomplete).
+		Bool("dry_run", dryRun).
+		Msg("Orphan cleanup report.")
+}
@@ -0,0 +1,69 @@
+package workflows
+
+import (
+	"time"
+
+	"manager/activities"
+	"manager/records"
+	"manager/types"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+var StaleTaskReaperName = "Manager-StaleTaskReaper"
+
+// StaleTaskReaper - Is a method that closes the tasks that are not done after
+// the configured age (by default records.TaskTTLDays). The prompts without a
+// response are recorded as timeouts against the supplier and the whole task
+// tree is removed. The tasks are processed in batches, up to MaxBatches per
+// run.
+func (wCtx *Ctx) StaleTaskReaper(ctx workflow.Context, params types.StaleTaskReaperParams) (*types.StaleTaskReaperResults, error) {
+
+	l := wCtx.App.Logger
+	l.Debug().Msg("Starting Stale Task Reaper Workflow.")
+
+	// Create result
+	result := types.StaleTaskReaperResults{}
+
+	// Set defaults
+	if params.MaxAgeHours == 0 {
+		params.MaxAgeHours = records.StaleTasksDefaultMaxAgeHours
+	}
+	if params.BatchSize == 0 {
+		params.BatchSize = records.StaleTasksDefaultBatchSize
+	}
+	if params.MaxBatches == 0 {
+		params.MaxBatches = records.StaleTasksDefaultMaxBatches
+	}
+
+	// -------------------------------------------------------------------------
+	// -------------------- Reap Stale Tasks -----------------------------------
+	// -------------------------------------------------------------------------
+	ctxTimeout := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ScheduleToStartTimeout: time.Minute * 5,
+		StartToCloseTimeout:    time.Minute * 15,
+	})
+	// Set activity input
+	reapStaleTasksInput := types.ReapStaleTasksParams{
+		OlderThan: workflow.Now(ctx).UTC().Add(-time.Duration(params.MaxAgeHours) * time.Hour),
+		BatchSize: params.BatchSize,
+	}
+	for batch := uint(0); batch < params.MaxBatches; batch++ {
+		// Results will be kept logged by temporal
+		var reapStaleTasksData types.ReapStaleTasksResults
+		// Execute activity
+		err := workflow.ExecuteActivity(ctxTimeout, activities.ReapStaleTasksName, reapStaleTasksInput).Get(ctx, &reapStaleTasksData)
+		if err != nil {
+			return &result, err
+		}
+		result.Reaped += reapStaleTasksData.Reaped
+		result.TimeoutSamples += reapStaleTasksData.TimeoutSamples
+		result.Unrecorded += reapStaleTasksData.Unrecorded
+		result.Skipped += reapStaleTasksData.Skipped
+		if !reapStaleTasksData.More {
+			break
+		}
+	}
+
+	return &result, nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"manager/activities"
+	"manager/records"
 	"manager/types"
 
 	"go.temporal.io/sdk/temporal"
@@ -31,6 +32,30 @@ func (wCtx *Ctx) SupplierManager(ctx workflow.Context, params types.SupplierMana
 		l.Error().Msg("Tests array cannot be empty.")
 		return &result, fmt.Errorf("tests array cannot be empty")
 	}
+	for _, test := range params.Tests {
+		if _, ok := wCtx.App.Config.Frameworks[test.Framework]; !ok {
+			l.Error().Str("framework", test.Framework).Msg("Tests framework not found in configuration.")
+			return &result, fmt.Errorf("tests framework %s not found in configuration", test.Framework)
+		}
+	}
+	// Check that the dependencies of the tests are part of them, if not, they
+	// must be triggered by other calls to this workflow (i.e. the signatures
+	// have their own schedule) or the tests depending on them will never be
+	// triggered. Unknown tasks and cycles are rejected when the configuration
+	// is loaded, so this is only a warning unless strict dependencies are
+	// requested.
+	dependencyGraph, ok := records.GetDependencyGraph()
+	if !ok {
+		l.Error().Msg("Task dependency graph not validated.")
+		return &result, fmt.Errorf("task dependency graph not validated")
+	}
+	for _, dep := range dependencyGraph.GetMissingDependencies(params.Tests) {
+		if params.StrictDependencies {
+			l.Error().Str("service", params.Service).Str("dependency", dep.String()).Msg("Tests depend on a task that is not part of them.")
+			return &result, fmt.Errorf("tests depend on %s, which is not part of them", dep.String())
+		}
+		l.Warn().Str("service", params.Service).Str("dependency", dep.String()).Msg("Tests depend on a task that is not part of them, it must be triggered by another workflow.")
+	}
 
 	// -------------------------------------------------------------------------
 	// -------------------- Get suppliers --------------------------------------
@@ -89,10 +114,11 @@ func (wCtx *Ctx) SupplierManager(ctx workflow.Context, params types.SupplierMana
 	// -------------------------------------------------------------------------
 	// -------------------- Get Randomness Seed --------------------------------
 	// -------------------------------------------------------------------------
-	// TODO: Make this configurable. Currently all tasks triggered will sample
-	//       the same docs (if possible due to blacklist). This is something you
-	//		 may want for signatures or an ordered test, but makes the testing
-	//		 process easier to detect by the backends.
+	// This is the seed of the "shared" seed strategy, the other strategies of
+	// the frameworks derive the seed of each trigger from it (see
+	// GetTriggerRandomSeed). Sharing the seed makes all suppliers sample the
+	// same docs, which is something you may want for signatures or an ordered
+	// test, but makes the testing process easier to detect by the backends.
 	var randomSeed int
 	ctxTimeout := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
 		ScheduleToStartTimeout: time.Second * 5,
@@ -163,9 +189,28 @@ func (wCtx *Ctx) SupplierManager(ctx workflow.Context, params types.SupplierMana
 		if response.Response.IsNew {
 			result.NewSuppliers += 1
 		}
-		result.TriggeredTasks += uint(len(response.Response.Triggers))
 	}
 
+	// -------------------------------------------------------------------------
+	// -------------------- Apply Trigger Budget -------------------------------
+	// -------------------------------------------------------------------------
+	budgetCfg := wCtx.App.Config.TriggerBudget
+	budget := records.GetTriggerBudget(budgetCfg, params.Service)
+	if budget > 0 {
+		requested := len(allTriggers)
+		allTriggers, result.DeferredSamples = records.ApplyTriggerBudget(allTriggers, budget, budgetCfg.StaleHours, workflow.Now(ctx).UTC())
+		if result.DeferredSamples > 0 {
+			l.Info().
+				Str("service", params.Service).
+				Uint32("budget", budget).
+				Int("requested_triggers", requested).
+				Int("kept_triggers", len(allTriggers)).
+				Uint("deferred_samples", result.DeferredSamples).
+				Msg("Trigger budget exceeded, samples deferred to the next run.")
+		}
+	}
+	result.TriggeredTasks = uint(len(allTriggers))
+
 	// -------------------------------------------------------------------------
 	// -------------------- Trigger Sampler ------------------------------------
 	// -------------------------------------------------------------------------
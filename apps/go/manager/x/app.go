@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"manager/activities"
+	"manager/records"
 	"manager/types"
 	"manager/workflows"
 	"os"
@@ -83,18 +84,23 @@ func Initialize() *types.App {
 	}
 
 	// initialize mongodb
-	m := mongodb.NewClient(cfg.MongodbUri, []string{
+	collections := []string{
 		types.TaskCollection,
 		types.InstanceCollection,
 		types.SuppliersCollection,
 		types.ResultsCollection,
 		types.PromptsCollection,
 		types.ResponsesCollection,
-		types.NumericalTaskCollection,
-		types.SignaturesTaskCollection,
 		types.TaxonomySummariesCollection,
 		types.TackedTaskSamplesCollection,
-	}, l)
+		types.SupplierEventsCollection,
+		types.SupplierAvailabilityCollection,
+		types.SupplierDuplicatesCollection,
+		types.SupplierBackendGroupsCollection,
+	}
+	// Add the buffers collections of all registered task types
+	collections = append(collections, records.GetTaskTypesCollections()...)
+	m := mongodb.NewClient(cfg.MongodbUri, collections, l)
 
 	// Create LazyNode
 	nodeConfig := shannon_types.FullNodeConfig{
@@ -236,5 +242,23 @@ func LoadConfigFile() *types.Config {
 		log.Fatal().Err(err).Str("Path", configFilePath).Msg("cannot read config file into json")
 	}
 
+	// Check the frameworks configuration (task types, buffers, dependencies, etc.)
+	err = records.ValidateFrameworksConfig(c.Frameworks)
+	if err != nil {
+		log.Fatal().Err(err).Str("Path", configFilePath).Msg("invalid frameworks configuration")
+	}
+	err = records.ValidateReputationConfig(c.Reputation, c.Frameworks)
+	if err != nil {
+		log.Fatal().Err(err).Str("Path", configFilePath).Msg("invalid reputation configuration")
+	}
+	err = records.ValidateSamplingConfig(c.Sampling)
+	if err != nil {
+		log.Fatal().Err(err).Str("Path", configFilePath).Msg("invalid sampling configuration")
+	}
+	err = records.ValidateTriggerBudgetConfig(c.TriggerBudget)
+	if err != nil {
+		log.Fatal().Err(err).Str("Path", configFilePath).Msg("invalid trigger budget configuration")
+	}
+
 	return &c
 }
@@ -66,6 +66,7 @@ async def sign_sample(args: PocketNetworkTaskRequest) -> bool:
     insert_mongo_tasks = []
     insert_mongo_prompt = []
     insert_mongo_instances = []
+    task.random_seed = args.random_seed
     insert_mongo_tasks.append(task.model_dump(by_alias=True))
     logger.debug("Task:", task=task)
     # Instances
@@ -45,12 +45,17 @@ async def summarize_taxonomy(
 
     # Fill with taxonomy nodes
     valid_node_data = False
+    # Samples of each node, used to weight the root error rate
+    nodes_samples = dict()
+    nodes_samples_failed = dict()
     for node in taxonomy_graph.nodes:
         running_score_total = 0
         running_score_square_dev = 0
         running_time_total = 0
         running_time_square_dev = 0
         runnning_n = 0
+        running_samples_total = 0
+        running_samples_failed = 0
         sample_min = np.inf
         if node == "root_c":
             continue
@@ -82,6 +87,10 @@ async def summarize_taxonomy(
             # Data
             this_result = docs[0]
 
+            # Track failed samples, this must be done before checking for valid
+            # samples, a dataset where all samples failed has an error rate of 1
+            running_samples_total += this_result["samples"]
+            running_samples_failed += this_result["samples"] * this_result["error_rate"]
             # Get number of samples here
             samples_here = int(this_result["samples"] * (1 - this_result["error_rate"]))
             if samples_here == 0:
@@ -101,6 +110,13 @@ async def summarize_taxonomy(
             # Track mean samples
             runnning_n += 1
 
+        # Error rate of the node, weighted by the number of samples of each dataset
+        node_error_rate = 0.0
+        if running_samples_total > 0:
+            node_error_rate = running_samples_failed / running_samples_total
+        nodes_samples[node] = running_samples_total
+        nodes_samples_failed[node] = running_samples_failed
+
         # Fill node metrics
         if runnning_n > 0:
             result.taxonomy_nodes_scores[node] = TaxonomyNodeSummary(
@@ -109,11 +125,17 @@ async def summarize_taxonomy(
                 run_time=running_time_total / runnning_n,
                 run_time_dev=np.sqrt(running_time_square_dev),
                 sample_min=sample_min,
+                error_rate=node_error_rate,
             )
             valid_node_data = True
         else:
             result.taxonomy_nodes_scores[node] = TaxonomyNodeSummary(
-                score=0, score_dev=0, run_time=0, run_time_dev=0, sample_min=0
+                score=0,
+                score_dev=0,
+                run_time=0,
+                run_time_dev=0,
+                sample_min=0,
+                error_rate=node_error_rate,
             )
 
     if not valid_node_data:
@@ -127,6 +149,8 @@ async def summarize_taxonomy(
     running_score_square_dev = 0
     running_time_total = 0
     running_time_square_dev = 0
+    running_samples_total = 0
+    running_samples_failed = 0
     runnning_n = 0
     sample_min = np.inf
     for edge in taxonomy_graph.edges("root_c"):
@@ -140,16 +164,26 @@ async def summarize_taxonomy(
             result.taxonomy_nodes_scores[edge[1]].run_time_dev ** 2
         )
 
+        # The root error rate is weighted by the samples of each node, nodes
+        # without samples must not lower it
+        running_samples_total += nodes_samples.get(edge[1], 0)
+        running_samples_failed += nodes_samples_failed.get(edge[1], 0)
+
         runnning_n += 1
         if sample_min > result.taxonomy_nodes_scores[edge[1]].sample_min:
             sample_min = result.taxonomy_nodes_scores[edge[1]].sample_min
 
+    root_error_rate = 0.0
+    if running_samples_total > 0:
+        root_error_rate = running_samples_failed / running_samples_total
+
     result.taxonomy_nodes_scores["root_c"] = TaxonomyNodeSummary(
         score=running_score_total / runnning_n,
         score_dev=np.sqrt(running_score_square_dev),
         run_time=running_time_total / runnning_n,
         run_time_dev=np.sqrt(running_time_square_dev),
         sample_min=sample_min,
+        error_rate=root_error_rate,
     )
 
     # Save result to mongo
@@ -64,6 +64,7 @@ async def supplier_snapshot(
                 run_time=doc["taxonomy_nodes_scores"][node]["run_time"],
                 run_time_dev=doc["taxonomy_nodes_scores"][node]["run_time_dev"],
                 sample_min=doc["taxonomy_nodes_scores"][node]["sample_min"],
+                error_rate=doc["taxonomy_nodes_scores"][node].get("error_rate", 0.0),
             )
 
     # Create snapshot entry
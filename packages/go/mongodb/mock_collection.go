@@ -144,3 +144,27 @@ func (c *MockCollection) DeleteOne(ctx context.Context, filter interface{}, opts
 	}
 	return
 }
+
+func (c *MockCollection) BulkWrite(ctx context.Context, models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (response *mongo.BulkWriteResult, e error) {
+	// BulkWriteResult is the result type returned by a BulkWrite operation.
+	// type BulkWriteResult struct {
+	//	 InsertedCount int64 // The number of documents inserted.
+	//	 MatchedCount  int64 // The number of documents matched by filters in update and replace operations.
+	//	 ModifiedCount int64 // The number of documents modified by update and replace operations.
+	//	 DeletedCount  int64 // The number of documents deleted.
+	//	 UpsertedCount int64 // The number of documents upserted by update and replace operations.
+	//	 UpsertedIDs   map[int64]interface{} // A map of operation index to the _id of each upserted document.
+	// }
+	args := c.Called(ctx, models, opts)
+	e = args.Error(1)
+	firstResponseArg := args.Get(0)
+
+	if firstResponseArg != nil {
+		if v, ok := firstResponseArg.(*mongo.BulkWriteResult); !ok {
+			return nil, UnexpectedType
+		} else {
+			response = v
+		}
+	}
+	return
+}
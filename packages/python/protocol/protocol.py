@@ -506,12 +506,16 @@ class PocketNetworkMongoDBTask(BaseModel):
     fewshot_as_multiturn: Optional[bool] = True
     confirm_run_unsafe_code: Optional[bool] = False
     qty: int
+    # Seed used by the sampler, kept to reproduce the sampled documents
+    random_seed: Optional[int] = None
     tasks: str
     total_instances: int
     request_type: str  # TODO : Remove, specific of LMEH
     id: PyObjectId = Field(default_factory=PyObjectId, alias="_id")
     done: bool = False
     evaluated: bool = False
+    # Incremented by the manager each time it sends the task back for evaluation
+    evaluation_retries: int = 0
     drop: bool = False
     creation_date: datetime = Field(default_factory=lambda: datetime.now(timezone.utc))
 
@@ -845,6 +849,9 @@ class TaxonomyNodeSummary(BaseModel):
     run_time: float
     run_time_dev: float
     sample_min: int
+    # Fraction of the samples that failed (relay or evaluation errors), older
+    # summaries do not have it
+    error_rate: float = 0.0
 
     # TODO : Extend this class to compute running means from passing a series of numerical buffers
 
@@ -127,6 +127,142 @@ def schedule_summary_task(interval="1h", execution_timeout=1200, task_timeout=12
     return run_command(command)
 
 
+def schedule_duplicates_task(interval="6h", execution_timeout=1200, task_timeout=1200):
+    command = BASE_COMMAND + [
+        "--",
+        "temporal",
+        "schedule",
+        "create",
+        "--schedule-id",
+        "duplicate-responses",
+        "--workflow-id",
+        "duplicate-responses",
+        "--type",
+        "Manager-DuplicateResponses",
+        "--task-queue",
+        "manager",
+        "--interval",
+        f"{interval}",
+        "--overlap-policy",
+        "Skip",
+        "--catchup-window",
+        "1s",
+        "--execution-timeout",
+        f"{execution_timeout}s",
+        "--run-timeout",
+        f"{execution_timeout}s",
+        "--task-timeout",
+        f"{task_timeout}s",
+        "--namespace",
+        f"{TEMPORAL_NAMESPACE}",
+        "--input",
+        '{"lookback_hours": 24}',
+    ]
+    return run_command(command)
+
+
+def schedule_backend_clusters_task(interval="24h", execution_timeout=1200, task_timeout=1200):
+    command = BASE_COMMAND + [
+        "--",
+        "temporal",
+        "schedule",
+        "create",
+        "--schedule-id",
+        "backend-clusters",
+        "--workflow-id",
+        "backend-clusters",
+        "--type",
+        "Manager-BackendClusters",
+        "--task-queue",
+        "manager",
+        "--interval",
+        f"{interval}",
+        "--overlap-policy",
+        "Skip",
+        "--catchup-window",
+        "1s",
+        "--execution-timeout",
+        f"{execution_timeout}s",
+        "--run-timeout",
+        f"{execution_timeout}s",
+        "--task-timeout",
+        f"{task_timeout}s",
+        "--namespace",
+        f"{TEMPORAL_NAMESPACE}",
+        "--input",
+        '{"lookback_hours": 72}',
+    ]
+    return run_command(command)
+
+
+def schedule_stale_task_reaper_task(interval="6h", execution_timeout=3600, task_timeout=3600):
+    command = BASE_COMMAND + [
+        "--",
+        "temporal",
+        "schedule",
+        "create",
+        "--schedule-id",
+        "stale-task-reaper",
+        "--workflow-id",
+        "stale-task-reaper",
+        "--type",
+        "Manager-StaleTaskReaper",
+        "--task-queue",
+        "manager",
+        "--interval",
+        f"{interval}",
+        "--overlap-policy",
+        "Skip",
+        "--catchup-window",
+        "1s",
+        "--execution-timeout",
+        f"{execution_timeout}s",
+        "--run-timeout",
+        f"{execution_timeout}s",
+        "--task-timeout",
+        f"{task_timeout}s",
+        "--namespace",
+        f"{TEMPORAL_NAMESPACE}",
+        "--input",
+        '{"max_age_hours": 24}',
+    ]
+    return run_command(command)
+
+
+def schedule_orphan_cleanup_task(interval="24h", execution_timeout=3600, task_timeout=3600):
+    command = BASE_COMMAND + [
+        "--",
+        "temporal",
+        "schedule",
+        "create",
+        "--schedule-id",
+        "orphan-cleanup",
+        "--workflow-id",
+        "orphan-cleanup",
+        "--type",
+        "Manager-OrphanCleanup",
+        "--task-queue",
+        "manager",
+        "--interval",
+        f"{interval}",
+        "--overlap-policy",
+        "Skip",
+        "--catchup-window",
+        "1s",
+        "--execution-timeout",
+        f"{execution_timeout}s",
+        "--run-timeout",
+        f"{execution_timeout}s",
+        "--task-timeout",
+        f"{task_timeout}s",
+        "--namespace",
+        f"{TEMPORAL_NAMESPACE}",
+        "--input",
+        '{"grace_minutes": 60}',
+    ]
+    return run_command(command)
+
+
 def schedule_requester_task(
     app_address, chain_id, interval="1m", execution_timeout=350, task_timeout=175
 ):
@@ -474,6 +610,30 @@ def main():
         default="24h",
         help="Interval for snapshot tasks (default: 24h)",
     )
+    parser.add_argument(
+        "--duplicates-interval",
+        type=validate_interval,
+        default="6h",
+        help="Interval for the duplicate responses search (default: 6h)",
+    )
+    parser.add_argument(
+        "--backend-clusters-interval",
+        type=validate_interval,
+        default="24h",
+        help="Interval for the backend clustering of suppliers (default: 24h)",
+    )
+    parser.add_argument(
+        "--stale-task-reaper-interval",
+        type=validate_interval,
+        default="6h",
+        help="Interval for the removal of stale tasks (default: 6h)",
+    )
+    parser.add_argument(
+        "--orphan-cleanup-interval",
+        type=validate_interval,
+        default="24h",
+        help="Interval for the removal of orphan task documents (default: 24h)",
+    )
     parser.add_argument(
         "--phase-offset",
         type=int,
@@ -492,6 +652,10 @@ def main():
     lookup_interval = args.lookup_interval
     summary_interval = args.summary_interval
     snapshot_interval = args.snapshot_interval
+    duplicates_interval = args.duplicates_interval
+    backend_clusters_interval = args.backend_clusters_interval
+    stale_task_reaper_interval = args.stale_task_reaper_interval
+    orphan_cleanup_interval = args.orphan_cleanup_interval
     phase_offset = args.phase_offset
 
     # Validate taxonomy if provided
@@ -697,6 +861,36 @@ def main():
         print("Snapshot scheduled.")
         time.sleep(0.25)
 
+        schedule_duplicates_task(
+            interval=duplicates_interval, execution_timeout=1200, task_timeout=1200
+        )
+        print("Duplicate responses search scheduled.")
+        time.sleep(0.25)
+
+        schedule_backend_clusters_task(
+            interval=backend_clusters_interval,
+            execution_timeout=1200,
+            task_timeout=1200,
+        )
+        print("Backend clustering scheduled.")
+        time.sleep(0.25)
+
+        schedule_stale_task_reaper_task(
+            interval=stale_task_reaper_interval,
+            execution_timeout=3600,
+            task_timeout=3600,
+        )
+        print("Stale task reaper scheduled.")
+        time.sleep(0.25)
+
+        schedule_orphan_cleanup_task(
+            interval=orphan_cleanup_interval,
+            execution_timeout=14400,
+            task_timeout=3600,
+        )
+        print("Orphan cleanup scheduled.")
+        time.sleep(0.25)
+
         # Create per-service tasks
         for chain_id in APPS_PER_SERVICE.keys():
             print(f"Triggering requesters for {chain_id} apps':")